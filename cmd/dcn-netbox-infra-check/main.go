@@ -1,98 +1,217 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"flag"
+	"io"
 	"log"
-	"strings"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
 	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/client"
 	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/report"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/runner"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/scheduler"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/server"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/snapshot"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
 	}
 
-	// Create API clients
-	netboxClient := client.NewNetboxClient(cfg.NetboxURL, cfg.NetboxAPIToken)
-	namClient := client.NewNAMClient(cfg.NAMURL, cfg.NAMAPIToken)
+	os.Exit(runOnce(os.Args[1:]))
+}
 
-	// Create Slack client
-	// slackClient := client.NewSlackClient(cfg.SlackWebhook)
+// exitConfigError is returned when the run could not be started
+const exitConfigError = 1
+
+// runOnce runs every check once and returns the exit code of the run
+func runOnce(args []string) int {
+	flags := flag.NewFlagSet("dcn-netbox-infra-check", flag.ContinueOnError)
+	format := flags.String("format", "text", "report format: text or json")
+	output := flags.String("output", "-", "file to write the JSON report to, '-' for stdout")
+	fix := flags.Bool("fix", false, "print a plan that fixes mechanical findings in Netbox (dry-run)")
+	confirm := flags.Bool("confirm", false, "apply the -fix plan to Netbox")
+	recordDir := flags.String("record-dir", "", "save the fetched Netbox and NAM data of the run below this directory")
+	replayDir := flags.String("replay-dir", "", "run the checks on a recorded run directory instead of the APIs")
+	if err := flags.Parse(args); err != nil {
+		return exitConfigError
+	}
 
-	// Fetch NAM VxLANs once (shared across all checks)
-	namVxLANs, err := namClient.FetchVxLANs()
-	if err != nil {
-		log.Fatalf("✗ Failed to fetch NAM VxLANs: %v", err)
+	if *format != "text" && *format != "json" {
+		log.Printf("Unknown report format %q, expected text or json", *format)
+		return exitConfigError
 	}
 
-	if len(namVxLANs) == 0 {
-		log.Fatal("✗ No NAM VxLANs fetched - check API URL or token")
+	if *confirm && !*fix {
+		log.Printf("-confirm requires -fix")
+		return exitConfigError
 	}
 
-	// Process each check
-	for _, check := range cfg.Checks {
-		fmt.Printf("\n\n")
-		fmt.Printf("==================================\n")
-		fmt.Printf("Sjekker datasenter %s\n", strings.ToUpper(check.DCName))
-		fmt.Printf("==================================\n\n")
+	if *recordDir != "" && *replayDir != "" {
+		log.Printf("-record-dir and -replay-dir cannot be combined")
+		return exitConfigError
+	}
+
+	// Load configuration. A replay uses the config and time of the recorded
+	// run and does not need any API credentials.
+	var (
+		cfg      *config.Config
+		replayAt time.Time
+		err      error
+	)
+	if *replayDir != "" {
+		cfg, replayAt, err = snapshot.Open(*replayDir).LoadRun()
+	} else {
+		cfg, err = config.LoadConfig()
+	}
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return exitConfigError
+	}
 
-		// Fetch Netbox data for this site
-		netboxVLANs, err := netboxClient.FetchVLANs(check.NetboxSiteID)
-		if err != nil {
-			log.Fatalf("✗ Failed to fetch Netbox VLANs for site %d: %v", check.NetboxSiteID, err)
+	// Keep stdout clean for the JSON report when it is written there
+	var out io.Writer = os.Stdout
+	if *format == "json" && *output == "-" {
+		out = os.Stderr
+	}
+
+	// Create the API clients, recording the fetched pages or replaying them
+	netboxClient, namClient := newNetboxClient(cfg), newNAMClient(cfg)
+	switch {
+	case *replayDir != "":
+		replay := snapshot.Open(*replayDir)
+		netboxClient.ReplayFrom(replay)
+		namClient.ReplayFrom(replay)
+	case *recordDir != "":
+		startedAt := time.Now()
+		runDir := snapshot.RunDir(*recordDir, startedAt)
+		record := snapshot.Open(runDir)
+		if err := record.SaveRun(cfg, startedAt); err != nil {
+			log.Printf("✗ Failed to record the config of the run: %v", err)
 		}
+		netboxClient.RecordTo(record)
+		namClient.RecordTo(record)
+		log.Printf("Recording fetched data to %s", runDir)
+	}
 
-		netboxPrefixes, err := netboxClient.FetchPrefixes(check.NetboxSiteID)
-		if err != nil {
-			log.Fatalf("✗ Failed to fetch Netbox Prefixes for site %d: %v", check.NetboxSiteID, err)
+	// Run all checks, continuing past checks that fail
+	summary := runner.New(cfg, netboxClient, namClient, out, runner.Options{
+		Fix:        *fix,
+		ApplyFixes: *confirm,
+		Replay:     *replayDir != "",
+		At:         replayAt,
+	}).Run()
+	summary.Print(out)
+
+	// A report that cannot be written does not change the outcome of the run
+	if *format == "json" {
+		if err := writeJSONReport(*output, summary, cfg); err != nil {
+			log.Printf("✗ Failed to write JSON report: %v", err)
 		}
+	}
 
-		if len(netboxVLANs) == 0 {
-			log.Fatalf("✗ No Netbox VLANs fetched for site %d - check API URL or token", check.NetboxSiteID)
+	if *replayDir == "" {
+		if err := runner.ExportMetrics(cfg.Metrics); err != nil {
+			log.Printf("✗ Failed to export metrics: %v", err)
 		}
+	}
+
+	return summary.ExitCode()
+}
+
+// serve runs the checks on the configured schedule and serves the latest
+// results over HTTP until the process is stopped
+func serve(args []string) {
+	flags := flag.NewFlagSet("dcn-netbox-infra-check serve", flag.ExitOnError)
+	runAtStart := flags.Bool("run-at-start", true, "run the checks once at startup")
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	listenAddr := cfg.Serve.ListenAddr
+	if listenAddr == "" {
+		listenAddr = ":8080"
+	}
+
+	spec := cfg.Serve.Schedule
+	if spec == "" {
+		spec = "0 8 * * *"
+	}
+	schedule, err := scheduler.Parse(spec)
+	if err != nil {
+		log.Fatalf("Invalid schedule: %v", err)
+	}
 
-		// Perform checks
-		result := checker.Check(
-			check.DCName,
-			check.Infra,
-			netboxVLANs,
-			netboxPrefixes,
-			namVxLANs,
-			cfg,
-		)
-
-		// Print results to console
-		fmt.Print(result.Output)
-
-		// Send to Slack if there are mismatches
-		if result.HasMismatches {
-			// if cfg.SlackWebhook != "" {
-			// 	if err := slackClient.Send(result); err != nil {
-			// 		log.Printf("✗ Failed to send Slack notification: %v", err)
-			// 	}
-			// }
-
-			esmClient := client.NewESMClient(cfg.ESMURL, cfg.ESMUser, cfg.ESMPassword, cfg.ESMTenantID)
-
-			err = esmClient.Authenticate()
-			if err != nil {
-				log.Fatalf("✗ Failed to authenticate to ESM: %v", err)
-			}
-			request := esmClient.CreateRequest(result, check.DCName, check.Infra, cfg)
-			err = esmClient.SendRequest(request)
-			if err != nil {
-				log.Fatalf("✗ Failed to send ESM request: %v", err)
-			}
+	srv := server.New(cfg, runner.New(cfg, newNetboxClient(cfg), newNAMClient(cfg), os.Stdout, runner.Options{}))
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{
+		Addr:              listenAddr,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Serving HTTP API on %s, running checks on schedule %q", listenAddr, spec)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("✗ HTTP server failed: %v", err)
 		}
+	}()
+
+	if *runAtStart {
+		srv.TryRun()
 	}
+	go srv.Schedule(ctx, schedule)
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
 
-	fmt.Printf("\n======================\n")
-	fmt.Printf("Alle sjekker fullført!\n")
-	fmt.Printf("======================\n\n")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("✗ Failed to shut down HTTP server: %v", err)
+	}
+}
+
+// newNetboxClient creates the Netbox API client
+func newNetboxClient(cfg *config.Config) *client.NetboxClient {
+	return client.NewNetboxClient(cfg.NetboxURL, cfg.NetboxAPIToken, cfg.NetboxRetry)
+}
+
+// newNAMClient creates the NAM API client
+func newNAMClient(cfg *config.Config) *client.NAMClient {
+	return client.NewNAMClient(cfg.NAMURL, cfg.NAMAPIToken, cfg.NAMRetry)
+}
+
+// writeJSONReport writes the JSON report to path, or stdout if path is "-"
+func writeJSONReport(path string, summary *runner.Summary, cfg *config.Config) error {
+	if path == "-" {
+		return report.WriteJSON(os.Stdout, summary, cfg)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := report.WriteJSON(file, summary, cfg); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/fake"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/report"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/runner"
+)
+
+// env is a working directory with config and secrets pointing at fake APIs
+type env struct {
+	netbox *fake.Netbox
+	nam    *fake.NAM
+	esm    *fake.ESM
+	dir    string
+	config map[string]interface{}
+}
+
+// newEnv starts the fake APIs and writes the config for two DCs: dc1 is
+// clean and dc2 has a name mismatch and a prefix with the wrong infra
+func newEnv(t *testing.T) *env {
+	t.Helper()
+
+	e := &env{
+		netbox: fake.NewNetbox(),
+		nam:    fake.NewNAM(),
+		esm:    fake.NewESM(),
+		dir:    t.TempDir(),
+	}
+	t.Cleanup(e.netbox.Close)
+	t.Cleanup(e.nam.Close)
+	t.Cleanup(e.esm.Close)
+
+	// Small pages so pagination is exercised
+	e.netbox.PageSize = 1
+	e.nam.PageSize = 1
+
+	e.nam.SetVxLANs(
+		vxlan(100, "app-100", "dc1"),
+		vxlan(200, "db-200", "dc2"),
+	)
+	e.nam.SetSubnets(
+		subnet(1, "10.0.0.0/24", 100),
+		subnet(2, "10.0.1.0/24", 200),
+	)
+	e.netbox.SetVLANs(1, vlan(1, 100, "app-100", "infra-a"))
+	e.netbox.SetPrefixes(1, prefix(10, "10.0.0.0/24", 1, 100, "app-100", "infra-a"))
+	e.netbox.SetVLANs(2, vlan(2, 200, "db-old", "infra-a"))
+	e.netbox.SetPrefixes(2, prefix(20, "10.0.1.0/24", 2, 200, "db-200", "infra-b"))
+
+	retry := map[string]int{"max_attempts": 1, "initial_backoff_ms": 1, "max_backoff_ms": 1}
+	e.config = map[string]interface{}{
+		"netbox_url":    e.netbox.URL,
+		"nam_url":       e.nam.URL,
+		"esm_url":       e.esm.URL,
+		"esm_user":      "checker",
+		"esm_tenant_id": 1,
+		"checks": []map[string]interface{}{
+			{"netbox_site_id": 1, "infra": "infra-a", "dc_name": "dc1"},
+			{"netbox_site_id": 2, "infra": "infra-a", "dc_name": "dc2"},
+		},
+		"netbox_retry": retry,
+		"nam_retry":    retry,
+		"esm_retry":    retry,
+	}
+	e.writeConfig(t)
+	for _, name := range []string{"netbox", "nam", "esm"} {
+		writeFile(t, filepath.Join(e.dir, "secrets", name+".secret"), "secret\n")
+	}
+
+	t.Chdir(e.dir)
+	return e
+}
+
+// set changes a setting in config.json
+func (e *env) set(t *testing.T, key string, value interface{}) {
+	t.Helper()
+	e.config[key] = value
+	e.writeConfig(t)
+}
+
+// writeConfig writes config.json
+func (e *env) writeConfig(t *testing.T) {
+	t.Helper()
+	data, err := json.Marshal(e.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(e.dir, "config", "config.json"), string(data))
+}
+
+// run runs the checks once with a JSON report and returns the exit code
+// and the report
+func (e *env) run(t *testing.T, args ...string) (int, *report.Report) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	code := runOnce(append([]string{"-format", "json", "-output", path}, args...))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("no report written (exit code %d): %v", code, err)
+	}
+	var rep report.Report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	return code, &rep
+}
+
+func TestRunReportsFindings(t *testing.T) {
+	e := newEnv(t)
+
+	code, rep := e.run(t)
+	if code != runner.ExitOK {
+		t.Fatalf("exit code = %d, want %d", code, runner.ExitOK)
+	}
+
+	dc1, dc2 := result(t, rep, "dc1"), result(t, rep, "dc2")
+	if dc1.HasMismatches {
+		t.Errorf("dc1 has mismatches, want clean")
+	}
+	if len(dc2.NameMismatches) != 1 || len(dc2.WrongPrefixes) != 1 {
+		t.Errorf("dc2 name mismatches = %d, wrong prefixes = %d, want 1 and 1", len(dc2.NameMismatches), len(dc2.WrongPrefixes))
+	}
+
+	if nm := dc2.NameMismatches; len(nm) == 1 && (len(nm[0].NetboxVLANs) != 1 || nm[0].NetboxVLANs[0].URL != e.netbox.URL+"/ipam/vlans/2/") {
+		t.Errorf("dc2 name mismatch Netbox VLANs = %+v, want VLAN 2 with a link", nm[0].NetboxVLANs)
+	}
+
+	requests := e.esm.Requests()
+	if len(requests) != 1 || !strings.Contains(requests[0].DisplayLabel, "dc2") {
+		t.Fatalf("ESM requests = %+v, want one for dc2", requests)
+	}
+	if !strings.Contains(requests[0].Description, "Netbox='db-old'") {
+		t.Errorf("ESM description does not show the Netbox name: %s", requests[0].Description)
+	}
+}
+
+func TestRunUpdatesESMRequest(t *testing.T) {
+	e := newEnv(t)
+	e.run(t)
+
+	// Same findings: the open request is left alone
+	e.run(t)
+	requests := e.esm.Requests()
+	if len(requests) != 1 || len(requests[0].Comments) != 0 {
+		t.Fatalf("ESM requests after rerun = %+v, want one without comments", requests)
+	}
+
+	// Changed findings: the open request gets a comment
+	e.nam.SetVxLANs(
+		vxlan(100, "app-100", "dc1"),
+		vxlan(200, "db-200", "dc2"),
+		vxlan(201, "web-201", "dc2"),
+	)
+	e.run(t)
+	requests = e.esm.Requests()
+	if len(requests) != 1 || len(requests[0].Comments) != 1 {
+		t.Fatalf("ESM requests after change = %+v, want one with a comment", requests)
+	}
+	if !strings.Contains(requests[0].Description, "web-201") {
+		t.Errorf("description not updated with the new finding: %s", requests[0].Description)
+	}
+
+	// No findings: the open request is closed
+	e.nam.SetVxLANs(
+		vxlan(100, "app-100", "dc1"),
+		vxlan(200, "db-200", "dc2"),
+	)
+	e.netbox.SetVLANs(2, vlan(2, 200, "db-200", "infra-a"))
+	e.netbox.SetPrefixes(2, prefix(20, "10.0.1.0/24", 2, 200, "db-200", "infra-a"))
+	e.run(t)
+	requests = e.esm.Requests()
+	if len(requests) != 1 || requests[0].Status != "RequestStatusComplete" {
+		t.Fatalf("ESM requests after fix = %+v, want one completed", requests)
+	}
+}
+
+func TestRunMovedVLANs(t *testing.T) {
+	e := newEnv(t)
+	e.nam.SetVxLANs(
+		vxlan(100, "app-nam-01", "dc1"),
+		vxlan(200, "db-200", "dc2"),
+	)
+	e.netbox.SetVLANs(1, vlan(1, 100, "app-nam-03", "infra-a"))
+
+	_, rep := e.run(t, "-fix", "-confirm")
+	dc1 := result(t, rep, "dc1")
+	if len(dc1.MovedVLANs) != 1 || dc1.MovedVLANs[0].Rule != "nam-03" {
+		t.Errorf("moved VLANs = %+v, want one matched by the default nam-03 rule", dc1.MovedVLANs)
+	}
+	if len(dc1.NameMismatches) != 0 {
+		t.Errorf("name mismatches = %+v, want none for a moved VLAN", dc1.NameMismatches)
+	}
+	for _, p := range e.netbox.Patches() {
+		if p.ObjectType == "vlan" && p.ID == 1 {
+			t.Errorf("fix renamed the moved VLAN: %+v", p)
+		}
+	}
+}
+
+func TestRunSubnetsUnavailable(t *testing.T) {
+	e := newEnv(t)
+	e.nam.FailSubnets(http.StatusNotFound)
+
+	code, rep := e.run(t)
+	if code != runner.ExitOK {
+		t.Fatalf("exit code = %d, want %d", code, runner.ExitOK)
+	}
+	dc2 := result(t, rep, "dc2")
+	if !reflect.DeepEqual(dc2.SkippedChecks, []string{"subnet_mismatch"}) {
+		t.Errorf("skipped checks = %v, want subnet_mismatch", dc2.SkippedChecks)
+	}
+	if len(dc2.NameMismatches) != 1 || len(dc2.WrongPrefixes) != 1 {
+		t.Errorf("dc2 name mismatches = %d, wrong prefixes = %d, want the other checks to run", len(dc2.NameMismatches), len(dc2.WrongPrefixes))
+	}
+}
+
+func TestRunSubnetsUnavailableKeepsESMRequests(t *testing.T) {
+	e := newEnv(t)
+
+	// dc1 only has subnet findings, dc2 has a subnet finding among others
+	e.nam.SetSubnets(
+		subnet(1, "10.0.9.0/24", 100),
+		subnet(2, "10.0.1.0/24", 200),
+		subnet(3, "10.9.0.0/24", 200),
+	)
+	e.run(t)
+	before := e.esm.Requests()
+	if len(before) != 2 {
+		t.Fatalf("ESM requests = %+v, want one per DC", before)
+	}
+
+	e.nam.FailSubnets(http.StatusInternalServerError)
+	e.run(t)
+	if after := e.esm.Requests(); !reflect.DeepEqual(after, before) {
+		t.Errorf("ESM requests changed while the subnet check was skipped:\nbefore: %+v\nafter:  %+v", before, after)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	e := newEnv(t)
+
+	e.netbox.FailSite(2, http.StatusInternalServerError)
+	code, rep := e.run(t)
+	if code != runner.ExitPartialRun {
+		t.Errorf("exit code with one failing site = %d, want %d", code, runner.ExitPartialRun)
+	}
+	if len(rep.Failures) != 1 {
+		t.Errorf("failures = %d, want 1", len(rep.Failures))
+	}
+
+	// A report that cannot be written keeps the exit code of the run
+	missing := filepath.Join(t.TempDir(), "missing", "report.json")
+	if code := runOnce([]string{"-format", "json", "-output", missing}); code != runner.ExitPartialRun {
+		t.Errorf("exit code when the report cannot be written = %d, want %d", code, runner.ExitPartialRun)
+	}
+
+	e.netbox.FailSite(1, http.StatusInternalServerError)
+	if code, _ := e.run(t); code != runner.ExitAllFailed {
+		t.Errorf("exit code with all sites failing = %d, want %d", code, runner.ExitAllFailed)
+	}
+
+	if code := runOnce([]string{"-confirm"}); code != exitConfigError {
+		t.Errorf("exit code for -confirm without -fix = %d, want %d", code, exitConfigError)
+	}
+}
+
+func TestRunFix(t *testing.T) {
+	e := newEnv(t)
+
+	e.run(t, "-fix")
+	if patches := e.netbox.Patches(); len(patches) != 0 {
+		t.Fatalf("dry-run sent patches: %+v", patches)
+	}
+
+	e.run(t, "-fix", "-confirm")
+	want := []fake.Patch{
+		{ObjectType: "vlan", ID: 2, Fields: map[string]interface{}{"name": "db-200"}},
+		{ObjectType: "prefix", ID: 20, Fields: map[string]interface{}{"custom_fields": map[string]interface{}{"infra": "infra-a"}}},
+	}
+	if got := e.netbox.Patches(); !samePatches(got, want) {
+		t.Errorf("patches = %+v, want %+v", got, want)
+	}
+
+	// The dry run opened the request, so the fixes are posted as a comment
+	requests := e.esm.Requests()
+	if len(requests) != 1 || len(requests[0].Comments) != 1 || !strings.Contains(requests[0].Comments[0], "Utbedringer i Netbox") {
+		t.Fatalf("ESM requests = %+v, want one with the fixes as a comment", requests)
+	}
+
+	// The fake Netbox does not apply the patches, so the findings are
+	// unchanged. The fixes of the rerun are still posted.
+	e.run(t, "-fix", "-confirm")
+	if requests = e.esm.Requests(); len(requests) != 1 || len(requests[0].Comments) != 2 {
+		t.Errorf("ESM requests after rerun = %+v, want one with two comments", requests)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	e := newEnv(t)
+
+	recordDir := t.TempDir()
+	_, recorded := e.run(t, "-record-dir", recordDir, "-fix", "-confirm")
+	if patches := e.netbox.Patches(); len(patches) != 2 {
+		t.Errorf("patches while recording = %+v, want 2", patches)
+	}
+
+	runDirs, err := os.ReadDir(recordDir)
+	if err != nil || len(runDirs) != 1 {
+		t.Fatalf("record dir entries = %v (%v), want one run", runDirs, err)
+	}
+	runDir := filepath.Join(recordDir, runDirs[0].Name())
+
+	// The raw API pages are recorded, not the parsed models
+	page, err := os.ReadFile(filepath.Join(runDir, "netbox", "site-2-vlans", "page-1.json"))
+	if err != nil || !strings.Contains(string(page), `"count"`) {
+		t.Errorf("recorded Netbox page = %s (%v), want the raw response", page, err)
+	}
+
+	// The recorded config has no secrets
+	recordedConfig, err := os.ReadFile(filepath.Join(runDir, "config.json"))
+	if err != nil || strings.Contains(string(recordedConfig), "secret") {
+		t.Errorf("recorded config = %s (%v), want it without secrets", recordedConfig, err)
+	}
+
+	// The replay uses the recorded config, not the local one
+	e.set(t, "checks", []map[string]interface{}{
+		{"netbox_site_id": 2, "infra": "infra-b", "dc_name": "dc2"},
+	})
+	e.set(t, "suppressions", []map[string]interface{}{
+		{"dc_name": "dc2", "name_regex": ".*", "reason": "changed after the recording"},
+	})
+
+	// The APIs are down, the replay must not need them
+	e.netbox.FailSite(1, http.StatusInternalServerError)
+	e.netbox.FailSite(2, http.StatusInternalServerError)
+	e.nam.Fail(http.StatusInternalServerError)
+	requests := len(e.esm.Requests())
+
+	code, replayed := e.run(t, "-replay-dir", runDir)
+	if code != runner.ExitOK {
+		t.Fatalf("replay exit code = %d, want %d", code, runner.ExitOK)
+	}
+	if !reflect.DeepEqual(recorded.Results, replayed.Results) {
+		t.Errorf("replayed results differ:\nrecorded: %+v\nreplayed: %+v", recorded.Results, replayed.Results)
+	}
+	if got := len(e.esm.Requests()); got != requests {
+		t.Errorf("replay created ESM requests: %d, want %d", got, requests)
+	}
+}
+
+func TestRecordFailureDoesNotFailRun(t *testing.T) {
+	e := newEnv(t)
+
+	// A file where the record directory should be makes every write fail
+	recordDir := filepath.Join(t.TempDir(), "file")
+	writeFile(t, recordDir, "")
+
+	code, rep := e.run(t, "-record-dir", recordDir)
+	if code != runner.ExitOK || len(rep.Failures) != 0 {
+		t.Errorf("exit code = %d, failures = %+v, want %d and none", code, rep.Failures, runner.ExitOK)
+	}
+	if requests := e.esm.Requests(); len(requests) != 1 {
+		t.Errorf("ESM requests = %d, want 1", len(requests))
+	}
+}
+
+// result returns the report result for a DC
+func result(t *testing.T, rep *report.Report, dcName string) report.DCResult {
+	t.Helper()
+	for _, r := range rep.Results {
+		if r.DCName == dcName {
+			return r
+		}
+	}
+	t.Fatalf("no result for %s", dcName)
+	return report.DCResult{}
+}
+
+// samePatches compares patches ignoring their order
+func samePatches(got, want []fake.Patch) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if reflect.DeepEqual(g, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func vxlan(id int, name, container string) models.NAMVxLAN {
+	return models.NAMVxLAN{ID: id, Name: name, Containers: []models.Container{{ID: 1, Name: container}}}
+}
+
+func subnet(id int, cidr string, vxlanID int) models.NAMSubnet {
+	return models.NAMSubnet{ID: id, Prefix: cidr, VxLAN: &models.VxLANReference{ID: vxlanID}}
+}
+
+func vlan(id, vid int, name, infra string) models.NetboxVLAN {
+	return models.NetboxVLAN{ID: id, VID: vid, Name: name, CustomFields: map[string]interface{}{"infra": infra}}
+}
+
+func prefix(id int, cidr string, vlanID, vid int, vlanName, infra string) models.NetboxPrefix {
+	return models.NetboxPrefix{
+		ID:           id,
+		Prefix:       cidr,
+		VLAN:         &models.VLANReference{ID: vlanID, VID: vid, Name: vlanName},
+		CustomFields: map[string]interface{}{"infra": infra},
+	}
+}
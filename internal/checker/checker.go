@@ -3,6 +3,7 @@ package checker
 import (
 	"bytes"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
@@ -17,14 +18,51 @@ type Result struct {
 	HasMismatches      bool
 	MovedVLANs         []MovedVLAN
 	MisconfiguredVLANs []models.NAMVxLAN
-	NameMismatches     []models.NAMVxLAN
+	NameMismatches     []NameMismatch
 	WrongPrefixes      []WrongPrefix
+	StaleVLANs         []models.NetboxVLAN
+	Duplicates         []Duplicate
+	PrefixIssues       []PrefixIssue
+	SubnetMismatches   []SubnetMismatch
+	PrefixOverlaps     []PrefixOverlap
+	WrongVRFs          []WrongVRF
+	PolicyViolations   []PolicyViolation
+	Suppressed         []SuppressedFinding
+	Sources            SourceCounts
+	// SkippedChecks are the checks that could not run because their data
+	// could not be fetched
+	SkippedChecks []string
+
+	// stretched records the DC's stretched VxLANs for CheckStretchedVxLANs
+	stretched []stretchedPresence
+}
+
+// SourceCounts holds the number of objects the checks were based on
+type SourceCounts struct {
+	NetboxVLANs    int
+	NetboxPrefixes int
+	NAMVxLANs      int
+	DCVxLANs       int
+	NAMSubnets     int
+	InfraVLANs     int
 }
 
 // MovedVLAN represents a VLAN that was moved but not updated
 type MovedVLAN struct {
 	VxLAN      models.NAMVxLAN
 	NetboxVLAN models.NetboxVLAN
+	Rule       config.MigrationRule
+}
+
+// NameMismatch represents a VxLAN whose name differs from the name of the
+// Netbox VLAN with the same VID
+type NameMismatch struct {
+	VxLAN models.NAMVxLAN
+	// NetboxVLANs are the Netbox VLANs of the infra with the VxLAN's ID
+	NetboxVLANs []models.NetboxVLAN
+	// Similarity is the highest similarity (0-1) of the VxLAN name to the
+	// names of the Netbox VLANs with the same VID, nil if not scored
+	Similarity *float64
 }
 
 // WrongPrefix represents a prefix with incorrect infra
@@ -33,13 +71,15 @@ type WrongPrefix struct {
 	Prefix models.NetboxPrefix
 }
 
-// Check performs all VLAN checks for a given DC
+// Check performs all VLAN checks for a given DC. namSubnets is nil if the
+// NAM subnets could not be fetched, the subnet check is then skipped.
 func Check(
 	dcName string,
 	infra string,
 	netboxVLANs []models.NetboxVLAN,
 	netboxPrefixes []models.NetboxPrefix,
 	namVxLANs []models.NAMVxLAN,
+	namSubnets []models.NAMSubnet,
 	config *config.Config,
 ) *Result {
 	result := &Result{
@@ -53,17 +93,38 @@ func Check(
 	// Filter VLANs for this infra
 	infraVLANs := filterInfraVLANs(netboxVLANs, infra)
 
+	result.Sources = SourceCounts{
+		NetboxVLANs:    len(netboxVLANs),
+		NetboxPrefixes: len(netboxPrefixes),
+		NAMVxLANs:      len(namVxLANs),
+		DCVxLANs:       len(dcVxLANs),
+		NAMSubnets:     len(namSubnets),
+		InfraVLANs:     len(infraVLANs),
+	}
+
+	names := newNameMatcher(config.NameComparison)
+
 	// Perform checks
-	result.MovedVLANs = checkMovedVLANs(dcVxLANs, infraVLANs)
+	result.MovedVLANs = checkMovedVLANs(dcVxLANs, infraVLANs, migrationsFor(config.MigrationRules, infra), names)
 	result.MisconfiguredVLANs = checkMisconfiguredVLANs(dcVxLANs, infraVLANs, infra)
-	result.NameMismatches = checkNameMismatches(dcVxLANs, infraVLANs, result.MisconfiguredVLANs)
+	result.NameMismatches = checkNameMismatches(dcVxLANs, infraVLANs, result.MisconfiguredVLANs, result.MovedVLANs, names)
 	result.WrongPrefixes = checkWrongPrefixes(dcVxLANs, netboxPrefixes, infra)
+	result.StaleVLANs = checkStaleVLANs(dcVxLANs, infraVLANs)
+	result.Duplicates = checkDuplicates(dcVxLANs, infraVLANs, names)
+	site := siteInfras(config.Checks, dcName, infra)
+	result.PrefixIssues = checkPrefixIntegrity(netboxVLANs, netboxPrefixes, infra, site, result.WrongPrefixes)
+	if namSubnets != nil {
+		result.SubnetMismatches = checkSubnets(dcVxLANs, infraVLANs, netboxPrefixes, namSubnets)
+	} else {
+		result.SkippedChecks = append(result.SkippedChecks, CheckSubnetMismatches)
+	}
+	result.PrefixOverlaps = checkPrefixOverlaps(netboxPrefixes, infra, site)
+	result.WrongVRFs = checkWrongVRFs(dcVxLANs, infraVLANs, netboxPrefixes, config.VRFRules, dcName)
+	result.PolicyViolations = checkVLANPolicies(dcVxLANs, infraVLANs, config.VLANPolicies, infra)
+	result.stretched = checkStretchedPresence(dcVxLANs, infraVLANs)
 
 	// Set HasMismatches before generating output
-	result.HasMismatches = len(result.MovedVLANs) > 0 ||
-		len(result.MisconfiguredVLANs) > 0 ||
-		len(result.NameMismatches) > 0 ||
-		len(result.WrongPrefixes) > 0
+	result.HasMismatches = len(result.Findings()) > 0
 
 	// Generate output
 	result.Output = generateOutput(result, config)
@@ -75,13 +136,48 @@ func Check(
 func filterDCVxLANs(vxlans []models.NAMVxLAN, dcName string) []models.NAMVxLAN {
 	var filtered []models.NAMVxLAN
 	for _, vxlan := range vxlans {
-		if vxlan.GetContainerName() == dcName {
+		if vxlan.InContainer(dcName) {
 			filtered = append(filtered, vxlan)
 		}
 	}
 	return filtered
 }
 
+// siteInfras returns the infras checked in the Netbox site of a DC check, in
+// the order of the checks. The check's own infra is always included.
+func siteInfras(checks []config.Check, dcName, infra string) []string {
+	sites := make(map[int]bool)
+	for _, c := range checks {
+		if c.DCName == dcName && c.Infra == infra {
+			sites[c.NetboxSiteID] = true
+		}
+	}
+
+	var infras []string
+	for _, c := range checks {
+		if sites[c.NetboxSiteID] && !slices.Contains(infras, c.Infra) {
+			infras = append(infras, c.Infra)
+		}
+	}
+	if !slices.Contains(infras, infra) {
+		infras = append(infras, infra)
+	}
+	return infras
+}
+
+// owner returns the infra whose check reports a finding that concerns
+// objects of several infras: the first candidate checked in the site, or
+// the site's first checked infra if none of them is. Each check of the
+// site then reports the finding at most once between them.
+func owner(site []string, candidates ...string) string {
+	for _, infra := range candidates {
+		if infra != "" && slices.Contains(site, infra) {
+			return infra
+		}
+	}
+	return site[0]
+}
+
 // filterInfraVLANs filters VLANs for a specific infra
 func filterInfraVLANs(vlans []models.NetboxVLAN, infra string) []models.NetboxVLAN {
 	var filtered []models.NetboxVLAN
@@ -93,18 +189,24 @@ func filterInfraVLANs(vlans []models.NetboxVLAN, infra string) []models.NetboxVL
 	return filtered
 }
 
-// checkMovedVLANs finds VLANs moved to nam-03 but not updated in NAM
-func checkMovedVLANs(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN) []MovedVLAN {
+// checkMovedVLANs finds VLANs renamed by a migration in Netbox that still
+// have their old name in NAM
+func checkMovedVLANs(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN, migrations []migration, names *nameMatcher) []MovedVLAN {
 	var moved []MovedVLAN
 	for _, vxlan := range dcVxLANs {
 		for _, vlan := range infraVLANs {
-			if strings.Contains(vlan.Name, "nam-03") &&
-				vlan.VID == vxlan.ID &&
-				normalizeName(vxlan.Name) == normalizeName(strings.Replace(vlan.Name, "nam-03", "nam-01", -1)) {
-				moved = append(moved, MovedVLAN{
-					VxLAN:      vxlan,
-					NetboxVLAN: vlan,
-				})
+			if vlan.VID != vxlan.ID {
+				continue
+			}
+			for _, m := range migrations {
+				if oldName, ok := m.oldName(vlan.Name); ok && names.equal(vxlan.Name, oldName) {
+					moved = append(moved, MovedVLAN{
+						VxLAN:      vxlan,
+						NetboxVLAN: vlan,
+						Rule:       m.rule,
+					})
+					break
+				}
 			}
 		}
 	}
@@ -129,32 +231,51 @@ func checkMisconfiguredVLANs(dcVxLANs []models.NAMVxLAN, infraVLANs []models.Net
 	return misconfigured
 }
 
-// checkNameMismatches finds VxLANs with name mismatches
-func checkNameMismatches(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN, misconfigured []models.NAMVxLAN) []models.NAMVxLAN {
-	var mismatches []models.NAMVxLAN
+// checkNameMismatches finds VxLANs with name mismatches. Moved VLANs are
+// skipped, their NAM name is the stale one.
+func checkNameMismatches(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN, misconfigured []models.NAMVxLAN, moved []MovedVLAN, names *nameMatcher) []NameMismatch {
+	var mismatches []NameMismatch
 
-	// Create a map of misconfigured VLANs for quick lookup
-	misconfiguredMap := make(map[int]bool)
+	// Create a map of misconfigured and moved VLANs for quick lookup
+	skip := make(map[int]bool)
 	for _, v := range misconfigured {
-		misconfiguredMap[v.ID] = true
+		skip[v.ID] = true
+	}
+	for _, mv := range moved {
+		skip[mv.VxLAN.ID] = true
 	}
 
 	for _, vxlan := range dcVxLANs {
-		// Skip if already in misconfigured list
-		if misconfiguredMap[vxlan.ID] {
+		// Skip if already in misconfigured or moved list
+		if skip[vxlan.ID] {
 			continue
 		}
 
 		found := false
 		for _, vlan := range infraVLANs {
-			if vlan.VID == vxlan.ID && normalizeName(vxlan.Name) == normalizeName(vlan.Name) {
+			if vlan.VID == vxlan.ID && names.equal(vxlan.Name, vlan.Name) {
 				found = true
 				break
 			}
 		}
-		if !found {
-			mismatches = append(mismatches, vxlan)
+		if found {
+			continue
+		}
+
+		mismatch := NameMismatch{VxLAN: vxlan}
+		for _, vlan := range infraVLANs {
+			if vlan.VID == vxlan.ID {
+				mismatch.NetboxVLANs = append(mismatch.NetboxVLANs, vlan)
+			}
+		}
+		if names.score {
+			best := 0.0
+			for _, vlan := range mismatch.NetboxVLANs {
+				best = max(best, names.similarity(vxlan.Name, vlan.Name))
+			}
+			mismatch.Similarity = &best
 		}
+		mismatches = append(mismatches, mismatch)
 	}
 	return mismatches
 }
@@ -178,9 +299,21 @@ func checkWrongPrefixes(dcVxLANs []models.NAMVxLAN, prefixes []models.NetboxPref
 	return wrong
 }
 
-// normalizeName normalizes a name for comparison
-func normalizeName(name string) string {
-	return strings.ToLower(strings.TrimSpace(name))
+// checkStaleVLANs finds Netbox VLANs for the infra that have no matching
+// VxLAN in the DC's NAM container
+func checkStaleVLANs(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN) []models.NetboxVLAN {
+	vxlanIDs := make(map[int]bool)
+	for _, vxlan := range dcVxLANs {
+		vxlanIDs[vxlan.ID] = true
+	}
+
+	var stale []models.NetboxVLAN
+	for _, vlan := range infraVLANs {
+		if !vxlanIDs[vlan.VID] {
+			stale = append(stale, vlan)
+		}
+	}
+	return stale
 }
 
 // generateOutput creates formatted output text
@@ -190,12 +323,12 @@ func generateOutput(result *Result, config *config.Config) string {
 	if len(result.MovedVLANs) > 0 {
 		buf.WriteString(strings.Repeat("=", 75))
 		buf.WriteString("\n")
-		buf.WriteString(fmt.Sprintf("Vxlans i '%s' som ikke er oppdatert i NAM etter flytting til nam-03 for '%s'\n", result.DCName, result.Infra))
+		buf.WriteString(fmt.Sprintf("Vxlans i '%s' som ikke er oppdatert i NAM etter flytting for '%s'\n", result.DCName, result.Infra))
 		buf.WriteString(strings.Repeat("=", 75))
 		buf.WriteString("\n")
 		for _, mv := range result.MovedVLANs {
-			buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d] Netbox='%s' -> NAM='%s'\n",
-				mv.VxLAN.ID, mv.NetboxVLAN.Name, mv.VxLAN.Name))
+			buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d] Netbox='%s' -> NAM='%s' (regel: %s)\n",
+				mv.VxLAN.ID, mv.NetboxVLAN.Name, mv.VxLAN.Name, mv.Rule))
 		}
 		buf.WriteString("\n")
 	}
@@ -218,8 +351,15 @@ func generateOutput(result *Result, config *config.Config) string {
 		buf.WriteString(fmt.Sprintf("Vxlans i '%s' som ikke har samme navn i Netbox (%s)\n", result.DCName, config.NetboxURL))
 		buf.WriteString(strings.Repeat("=", 75))
 		buf.WriteString("\n")
-		for _, vxlan := range result.NameMismatches {
-			buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d]: -> %s\n", vxlan.ID, vxlan.Name))
+		for _, nm := range result.NameMismatches {
+			buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d]: -> %s", nm.VxLAN.ID, nm.VxLAN.Name))
+			if nm.Similarity != nil {
+				buf.WriteString(fmt.Sprintf(" (likhet %.0f%%)", *nm.Similarity*100))
+			}
+			buf.WriteString("\n")
+			for _, vlan := range nm.NetboxVLANs {
+				buf.WriteString(fmt.Sprintf("    Netbox='%s' %s\n", vlan.Name, vlan.URL(config.NetboxURL)))
+			}
 		}
 		buf.WriteString("\n")
 	}
@@ -237,9 +377,171 @@ func generateOutput(result *Result, config *config.Config) string {
 		buf.WriteString("\n")
 	}
 
+	if len(result.StaleVLANs) > 0 {
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Vlans registrert som '%s' i Netbox (%s) som ikke finnes i NAM for '%s'\n", result.Infra, config.NetboxURL, result.DCName))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, vlan := range result.StaleVLANs {
+			buf.WriteString(fmt.Sprintf("✗ [Netbox VLAN ID %d]: -> %s (%s)\n", vlan.VID, vlan.Name, vlan.URL(config.NetboxURL)))
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(result.Duplicates) > 0 {
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Duplikater blant '%s'-vlans i Netbox (%s) og vxlans i '%s' i NAM\n", result.Infra, config.NetboxURL, result.DCName))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, d := range result.Duplicates {
+			switch d.Kind {
+			case DuplicateVID:
+				buf.WriteString(fmt.Sprintf("✗ [Netbox VLAN ID %d]: %d vlans har samme VID\n", d.VID, len(d.NetboxVLANs)))
+			case DuplicateName:
+				buf.WriteString(fmt.Sprintf("✗ [Netbox navn '%s']: %d vlans har samme navn\n", d.Name, len(d.NetboxVLANs)))
+			case DuplicateVxLANID:
+				buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d]: %d vxlans har samme ID\n", d.VID, len(d.NAMVxLANs)))
+			}
+			for _, vlan := range d.NetboxVLANs {
+				buf.WriteString(fmt.Sprintf("    Netbox VID %d '%s' %s\n", vlan.VID, vlan.Name, vlan.URL(config.NetboxURL)))
+			}
+			for _, vxlan := range d.NAMVxLANs {
+				buf.WriteString(fmt.Sprintf("    NAM '%s'\n", vxlan.Name))
+			}
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(result.PrefixIssues) > 0 {
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Prefikser for '%s' i Netbox (%s) med manglende eller feil vlan\n", result.Infra, config.NetboxURL))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, issue := range result.PrefixIssues {
+			prefix := issue.Prefix
+			switch issue.Kind {
+			case PrefixNoVLAN:
+				buf.WriteString(fmt.Sprintf("✗ [Prefix %s]: mangler vlan (%s)\n", prefix.Prefix, prefix.URL(config.NetboxURL)))
+			case PrefixForeignVLAN:
+				buf.WriteString(fmt.Sprintf("✗ [Prefix %s]: vlan %d '%s' finnes ikke i siten (%s)\n",
+					prefix.Prefix, issue.VLAN.VID, issue.VLAN.Name, prefix.URL(config.NetboxURL)))
+			case PrefixInfraMismatch:
+				buf.WriteString(fmt.Sprintf("✗ [Prefix %s]: infra '%s', men vlan %d '%s' har infra '%s' (%s)\n",
+					prefix.Prefix, prefix.GetInfra(), issue.VLAN.VID, issue.VLAN.Name, issue.VLAN.GetInfra(), prefix.URL(config.NetboxURL)))
+			}
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(result.SubnetMismatches) > 0 {
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Subnett i NAM for '%s' som ikke stemmer med prefikser i Netbox (%s)\n", result.DCName, config.NetboxURL))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, m := range result.SubnetMismatches {
+			switch m.Kind {
+			case SubnetMissingInNetbox:
+				buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d]: subnett %s mangler i Netbox\n", m.VxLAN.ID, m.Subnet.Prefix))
+			case SubnetMissingInNAM:
+				buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d]: prefix %s mangler i NAM (%s)\n",
+					m.VxLAN.ID, m.Prefix.Prefix, m.Prefix.URL(config.NetboxURL)))
+			case SubnetCIDRMismatch:
+				buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d]: NAM='%s' -> Netbox='%s' (%s)\n",
+					m.VxLAN.ID, m.Subnet.Prefix, m.Prefix.Prefix, m.Prefix.URL(config.NetboxURL)))
+			}
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(result.PrefixOverlaps) > 0 {
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Overlappende prefikser for '%s' i Netbox (%s)\n", result.Infra, config.NetboxURL))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, o := range result.PrefixOverlaps {
+			vrf := o.Prefix.GetVRFName()
+			if vrf == "" {
+				vrf = "global"
+			}
+			switch o.Kind {
+			case OverlapDuplicate:
+				buf.WriteString(fmt.Sprintf("✗ [VRF %s]: %s er registrert flere ganger\n", vrf, o.Prefix.Prefix))
+			case OverlapCrossInfra:
+				buf.WriteString(fmt.Sprintf("✗ [VRF %s]: %s ('%s') overlapper med %s ('%s')\n",
+					vrf, o.Prefix.Prefix, o.Prefix.GetInfra(), o.Other.Prefix, o.Other.GetInfra()))
+			case OverlapUnnested:
+				buf.WriteString(fmt.Sprintf("✗ [VRF %s]: %s overlapper med %s, men er ikke en container\n", vrf, o.Prefix.Prefix, o.Other.Prefix))
+			}
+			buf.WriteString(fmt.Sprintf("    %s\n    %s\n", o.Prefix.URL(config.NetboxURL), o.Other.URL(config.NetboxURL)))
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(result.WrongVRFs) > 0 {
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Prefixes i '%s' som ligger i feil VRF i Netbox (%s)\n", result.DCName, config.NetboxURL))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, wv := range result.WrongVRFs {
+			buf.WriteString(fmt.Sprintf("✗ [NAM VLAN ID %d] -> %s ligger i VRF '%s', forventet '%s' (%s)\n",
+				wv.VxLAN.ID, wv.Prefix.Prefix, vrfName(wv.Prefix), wv.Expected, wv.Prefix.URL(config.NetboxURL)))
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(result.PolicyViolations) > 0 {
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Vlans registrert som '%s' i Netbox (%s) som ikke følger policy\n", result.Infra, config.NetboxURL))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, pv := range result.PolicyViolations {
+			line := fmt.Sprintf("✗ [Netbox VLAN ID %d]: -> %s ", pv.VLAN.VID, pv.VLAN.Name)
+			switch pv.Requirement {
+			case PolicyStatus:
+				line += fmt.Sprintf("har status '%s', tillatt: %s", pv.Actual, strings.Join(pv.Allowed, ", "))
+			case PolicyTenant:
+				line += "mangler tenant"
+			case PolicyRole:
+				line += fmt.Sprintf("har rolle '%s', tillatt: %s", pv.Actual, strings.Join(pv.Allowed, ", "))
+			case PolicyGroup:
+				line += fmt.Sprintf("har gruppe '%s', tillatt: %s", pv.Actual, strings.Join(pv.Allowed, ", "))
+			}
+			buf.WriteString(fmt.Sprintf("%s (%s)\n", line, pv.VLAN.URL(config.NetboxURL)))
+		}
+		buf.WriteString("\n")
+	}
+
+	for _, check := range result.SkippedChecks {
+		buf.WriteString(fmt.Sprintf("! Sjekken '%s' ble hoppet over, dataene kunne ikke hentes\n", check))
+	}
+
 	if !result.HasMismatches {
 		buf.WriteString("✓ Ingen avvik funnet!\n")
 	}
 
+	if len(result.Suppressed) > 0 {
+		buf.WriteString("\n")
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("Undertrykte avvik i '%s' for '%s' (%d)\n", result.DCName, result.Infra, len(result.Suppressed)))
+		buf.WriteString(strings.Repeat("=", 75))
+		buf.WriteString("\n")
+		for _, sf := range result.Suppressed {
+			line := fmt.Sprintf("- [%s] VLAN ID %d -> %s: %s", sf.Finding.Check, sf.Finding.VLANID, sf.Finding.Detail, sf.Suppression.Reason)
+			if sf.Suppression.Expires != "" {
+				line += fmt.Sprintf(" (til %s)", sf.Suppression.Expires)
+			}
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("\n")
+	}
+
 	return buf.String()
 }
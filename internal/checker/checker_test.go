@@ -0,0 +1,156 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckMovedVLANs(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.MigrationRule
+		vxlan string
+		vlan  string
+		want  string
+	}{
+		{"default rule", config.DefaultMigrationRules, "app-nam-01", "app-nam-03", "nam-03"},
+		{"default rule ignores case", config.DefaultMigrationRules, "App-NAM-01", "app-nam-03", "nam-03"},
+		{"same name is not moved", config.DefaultMigrationRules, "app-nam-03", "app-nam-03", ""},
+		{"other name is not moved", config.DefaultMigrationRules, "db-nam-01", "app-nam-03", ""},
+		{"regex rule", []config.MigrationRule{{Name: "site", Source: `^(\w+)-b$`, Target: "$1-a", Regex: true}}, "app-a", "app-b", "site"},
+		{"unnamed rule", []config.MigrationRule{{Source: "-new", Target: "-old"}}, "app-old", "app-new", "-new -> -old"},
+		{"rule of another infra", []config.MigrationRule{{Infra: "test", Source: "-new", Target: "-old"}}, "app-old", "app-new", ""},
+		{"first matching rule", []config.MigrationRule{{Name: "a", Source: "-new", Target: "-x"}, {Name: "b", Source: "-new", Target: "-old"}}, "app-old", "app-new", "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vxlans := []models.NAMVxLAN{testVxLAN(100, tt.vxlan, "dc1")}
+			vlans := []models.NetboxVLAN{testVLAN(1, 100, tt.vlan, "prod")}
+			names := newNameMatcher(config.NameComparison{})
+
+			var got string
+			if moved := checkMovedVLANs(vxlans, vlans, migrationsFor(tt.rules, "prod"), names); len(moved) > 0 {
+				got = moved[0].Rule.String()
+			}
+			if got != tt.want {
+				t.Errorf("moved by rule %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNameMismatches(t *testing.T) {
+	vlans := []models.NetboxVLAN{
+		testVLAN(1, 100, "app-100", "prod"),
+		testVLAN(2, 200, "db-old", "prod"),
+		testVLAN(3, 200, "db-older", "prod"),
+		testVLAN(4, 300, "web-nam-03", "prod"),
+	}
+
+	tests := []struct {
+		name  string
+		vxlan models.NAMVxLAN
+		want  []int
+	}{
+		{"same name", testVxLAN(100, "app-100", "dc1"), nil},
+		{"name differs in case", testVxLAN(100, "APP-100", "dc1"), nil},
+		{"name matches one of the VLANs", testVxLAN(200, "db-old", "dc1"), nil},
+		{"different name lists every VLAN with the VID", testVxLAN(200, "db-200", "dc1"), []int{2, 3}},
+		{"moved VLAN", testVxLAN(300, "web-nam-01", "dc1"), nil},
+		{"missing VLAN is misconfigured", testVxLAN(400, "new-400", "dc1"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Check("dc1", "prod", vlans, nil, []models.NAMVxLAN{tt.vxlan}, nil, &config.Config{MigrationRules: config.DefaultMigrationRules})
+
+			var got []int
+			for _, m := range result.NameMismatches {
+				for _, vlan := range m.NetboxVLANs {
+					got = append(got, vlan.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("name mismatch VLANs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNameMismatchSimilarity(t *testing.T) {
+	vlans := []models.NetboxVLAN{
+		testVLAN(1, 100, "app-1", "prod"),
+		testVLAN(2, 100, "xyz-100", "prod"),
+	}
+	names := newNameMatcher(config.NameComparison{Similarity: true})
+
+	mismatches := checkNameMismatches([]models.NAMVxLAN{testVxLAN(100, "app-100", "dc1")}, vlans, nil, nil, names)
+	if len(mismatches) != 1 || mismatches[0].Similarity == nil {
+		t.Fatalf("mismatches = %+v, want one with a similarity", mismatches)
+	}
+	if got := *mismatches[0].Similarity; got != 5.0/7 {
+		t.Errorf("similarity = %v, want the best of the VLANs, %v", got, 5.0/7)
+	}
+}
+
+func TestCheckSkipsSubnetsWithoutData(t *testing.T) {
+	vxlans := []models.NAMVxLAN{testVxLAN(100, "app-100", "dc1")}
+	vlans := []models.NetboxVLAN{testVLAN(1, 100, "app-100", "prod")}
+	prefixes := []models.NetboxPrefix{testPrefix(1, "10.0.0.0/24", &vlans[0], "prod")}
+
+	result := Check("dc1", "prod", vlans, prefixes, vxlans, nil, &config.Config{})
+	if !reflect.DeepEqual(result.SkippedChecks, []string{CheckSubnetMismatches}) || len(result.SubnetMismatches) != 0 {
+		t.Errorf("skipped = %v, subnet mismatches = %+v, want only the subnet check skipped", result.SkippedChecks, result.SubnetMismatches)
+	}
+
+	result = Check("dc1", "prod", vlans, prefixes, vxlans, []models.NAMSubnet{}, &config.Config{})
+	if len(result.SkippedChecks) != 0 || len(result.SubnetMismatches) != 1 {
+		t.Errorf("skipped = %v, subnet mismatches = %+v, want the prefix missing in NAM", result.SkippedChecks, result.SubnetMismatches)
+	}
+}
+
+func TestSiteInfras(t *testing.T) {
+	checks := []config.Check{
+		{NetboxSiteID: 1, Infra: "prod", DCName: "dc1"},
+		{NetboxSiteID: 2, Infra: "prod", DCName: "dc2"},
+		{NetboxSiteID: 1, Infra: "test", DCName: "dc1"},
+		{NetboxSiteID: 1, Infra: "prod", DCName: "dc1"},
+	}
+
+	tests := []struct {
+		dc, infra string
+		want      []string
+	}{
+		{"dc1", "test", []string{"prod", "test"}},
+		{"dc2", "prod", []string{"prod"}},
+		{"dc3", "dev", []string{"dev"}},
+	}
+	for _, tt := range tests {
+		if got := siteInfras(checks, tt.dc, tt.infra); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("siteInfras(%s, %s) = %v, want %v", tt.dc, tt.infra, got, tt.want)
+		}
+	}
+}
+
+func testVxLAN(id int, name string, containers ...string) models.NAMVxLAN {
+	vxlan := models.NAMVxLAN{ID: id, Name: name}
+	for i, container := range containers {
+		vxlan.Containers = append(vxlan.Containers, models.Container{ID: i + 1, Name: container})
+	}
+	return vxlan
+}
+
+func testSubnet(id int, cidr string, vxlanID int) models.NAMSubnet {
+	return models.NAMSubnet{ID: id, Prefix: cidr, VxLAN: &models.VxLANReference{ID: vxlanID}}
+}
+
+// details returns the details of findings of one kind
+func details[T interface{ Detail() string }](items []T) []string {
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Detail())
+	}
+	return got
+}
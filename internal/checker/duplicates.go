@@ -0,0 +1,83 @@
+package checker
+
+import (
+	"sort"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// Kinds of duplicates
+const (
+	DuplicateVID     = "vid"
+	DuplicateName    = "name"
+	DuplicateVxLANID = "vxlan_id"
+)
+
+// Duplicate is a VID or name shared by several Netbox VLANs of the site and
+// infra, or an ID shared by several VxLANs in the DC's NAM container
+type Duplicate struct {
+	Kind string
+	// VID is the duplicated VID or VxLAN ID, 0 for name duplicates
+	VID int
+	// Name is the duplicated name after normalisation, for name duplicates
+	Name        string
+	NetboxVLANs []models.NetboxVLAN
+	NAMVxLANs   []models.NAMVxLAN
+}
+
+// Detail describes what is duplicated, for the duplicate's finding
+func (d Duplicate) Detail() string {
+	if d.Kind == DuplicateName {
+		return d.Kind + " " + d.Name
+	}
+	return d.Kind
+}
+
+// checkDuplicates finds duplicate VIDs and names among the infra's Netbox
+// VLANs and duplicate IDs among the DC's VxLANs
+func checkDuplicates(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN, names *nameMatcher) []Duplicate {
+	var duplicates []Duplicate
+
+	byVID := make(map[int][]models.NetboxVLAN)
+	for _, vlan := range infraVLANs {
+		byVID[vlan.VID] = append(byVID[vlan.VID], vlan)
+	}
+	for _, vid := range sortedKeys(byVID) {
+		if vlans := byVID[vid]; len(vlans) > 1 {
+			duplicates = append(duplicates, Duplicate{Kind: DuplicateVID, VID: vid, NetboxVLANs: vlans})
+		}
+	}
+
+	byName := make(map[string][]models.NetboxVLAN)
+	for _, vlan := range infraVLANs {
+		name := names.normalize(vlan.Name)
+		byName[name] = append(byName[name], vlan)
+	}
+	for _, name := range sortedKeys(byName) {
+		if vlans := byName[name]; len(vlans) > 1 {
+			duplicates = append(duplicates, Duplicate{Kind: DuplicateName, Name: name, NetboxVLANs: vlans})
+		}
+	}
+
+	byID := make(map[int][]models.NAMVxLAN)
+	for _, vxlan := range dcVxLANs {
+		byID[vxlan.ID] = append(byID[vxlan.ID], vxlan)
+	}
+	for _, id := range sortedKeys(byID) {
+		if vxlans := byID[id]; len(vxlans) > 1 {
+			duplicates = append(duplicates, Duplicate{Kind: DuplicateVxLANID, VID: id, NAMVxLANs: vxlans})
+		}
+	}
+
+	return duplicates
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys[K int | string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,70 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckDuplicates(t *testing.T) {
+	tests := []struct {
+		name   string
+		vxlans []models.NAMVxLAN
+		vlans  []models.NetboxVLAN
+		want   []string
+	}{
+		{
+			name:   "unique",
+			vxlans: []models.NAMVxLAN{testVxLAN(100, "app-100", "dc1"), testVxLAN(200, "db-200", "dc1")},
+			vlans:  []models.NetboxVLAN{testVLAN(1, 100, "app-100", "prod"), testVLAN(2, 200, "db-200", "prod")},
+		},
+		{
+			name:  "same VID",
+			vlans: []models.NetboxVLAN{testVLAN(1, 100, "app-100", "prod"), testVLAN(2, 100, "app-b", "prod")},
+			want:  []string{"vid"},
+		},
+		{
+			name:  "same name after normalisation",
+			vlans: []models.NetboxVLAN{testVLAN(1, 100, "App-100", "prod"), testVLAN(2, 101, "app-100 ", "prod")},
+			want:  []string{"name app-100"},
+		},
+		{
+			name:  "same VID and name",
+			vlans: []models.NetboxVLAN{testVLAN(1, 100, "app-100", "prod"), testVLAN(2, 100, "app-100", "prod")},
+			want:  []string{"vid", "name app-100"},
+		},
+		{
+			name:   "same VxLAN ID",
+			vxlans: []models.NAMVxLAN{testVxLAN(100, "app-100", "dc1"), testVxLAN(100, "app-b", "dc1")},
+			want:   []string{"vxlan_id"},
+		},
+		{
+			name:  "sorted by VID",
+			vlans: []models.NetboxVLAN{testVLAN(1, 300, "c", "prod"), testVLAN(2, 300, "d", "prod"), testVLAN(3, 100, "a", "prod"), testVLAN(4, 100, "b", "prod")},
+			want:  []string{"vid", "vid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duplicates := checkDuplicates(tt.vxlans, tt.vlans, newNameMatcher(config.NameComparison{}))
+			if got := details(duplicates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("duplicates = %v, want %v", got, tt.want)
+			}
+			for i := 1; i < len(duplicates); i++ {
+				if duplicates[i].Kind == duplicates[i-1].Kind && duplicates[i].VID < duplicates[i-1].VID {
+					t.Errorf("duplicates not sorted: %+v", duplicates)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDuplicatesOnlyInfraVLANs(t *testing.T) {
+	vlans := []models.NetboxVLAN{testVLAN(1, 100, "app-100", "prod"), testVLAN(2, 100, "app-100", "test")}
+	result := Check("dc1", "prod", vlans, nil, nil, []models.NAMSubnet{}, &config.Config{})
+	if len(result.Duplicates) != 0 {
+		t.Errorf("duplicates = %+v, want none across infras", result.Duplicates)
+	}
+}
@@ -0,0 +1,171 @@
+package checker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// Check types identifying the check a finding comes from
+const (
+	CheckMovedVLANs         = "moved_vlan"
+	CheckMisconfiguredVLANs = "misconfigured_vlan"
+	CheckNameMismatches     = "name_mismatch"
+	CheckWrongPrefixes      = "wrong_prefix"
+	CheckStaleVLANs         = "stale_vlan"
+	CheckDuplicates         = "duplicate"
+	CheckPrefixIntegrity    = "prefix_integrity"
+	CheckSubnetMismatches   = "subnet_mismatch"
+	CheckPrefixOverlaps     = "prefix_overlap"
+	CheckWrongVRFs          = "wrong_vrf"
+	CheckVLANPolicies       = "vlan_policy"
+)
+
+// CheckTypes lists every check type in report order
+var CheckTypes = []string{
+	CheckMovedVLANs,
+	CheckMisconfiguredVLANs,
+	CheckNameMismatches,
+	CheckWrongPrefixes,
+	CheckStaleVLANs,
+	CheckDuplicates,
+	CheckPrefixIntegrity,
+	CheckSubnetMismatches,
+	CheckPrefixOverlaps,
+	CheckWrongVRFs,
+	CheckVLANPolicies,
+}
+
+// Finding is a single finding of a check, identified by the check type and
+// the VLAN ID it concerns
+type Finding struct {
+	Check  string
+	VLANID int
+	Detail string
+}
+
+// Key returns a string uniquely identifying the finding within a result
+func (f Finding) Key() string {
+	return fmt.Sprintf("%s|%d|%s", f.Check, f.VLANID, f.Detail)
+}
+
+// Findings returns every finding of the result as a flat list
+func (r *Result) Findings() []Finding {
+	var findings []Finding
+
+	findings = appendFindings(findings, r.MovedVLANs, describeMovedVLAN)
+	findings = appendFindings(findings, r.MisconfiguredVLANs, describeMisconfiguredVLAN)
+	findings = appendFindings(findings, r.NameMismatches, describeNameMismatch)
+	findings = appendFindings(findings, r.WrongPrefixes, describeWrongPrefix)
+	findings = appendFindings(findings, r.StaleVLANs, describeStaleVLAN)
+	findings = appendFindings(findings, r.Duplicates, describeDuplicate)
+	findings = appendFindings(findings, r.PrefixIssues, describePrefixIssue)
+	findings = appendFindings(findings, r.SubnetMismatches, describeSubnetMismatch)
+	findings = appendFindings(findings, r.PrefixOverlaps, describePrefixOverlap)
+	findings = appendFindings(findings, r.WrongVRFs, describeWrongVRF)
+	findings = appendFindings(findings, r.PolicyViolations, describePolicyViolation)
+
+	return findings
+}
+
+// appendFindings appends the findings of items to findings
+func appendFindings[T any](findings []Finding, items []T, describe func(T) (Finding, suppressionTarget)) []Finding {
+	for _, item := range items {
+		finding, _ := describe(item)
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// The describe functions below turn an item of a check into its finding and
+// the names and prefix suppressions are matched against. They are used both
+// to list the findings and to suppress them, so the two always agree.
+
+func describeMovedVLAN(mv MovedVLAN) (Finding, suppressionTarget) {
+	return Finding{Check: CheckMovedVLANs, VLANID: mv.VxLAN.ID, Detail: mv.NetboxVLAN.Name},
+		suppressionTarget{names: []string{mv.VxLAN.Name, mv.NetboxVLAN.Name}}
+}
+
+func describeMisconfiguredVLAN(vxlan models.NAMVxLAN) (Finding, suppressionTarget) {
+	return Finding{Check: CheckMisconfiguredVLANs, VLANID: vxlan.ID, Detail: vxlan.Name},
+		suppressionTarget{names: []string{vxlan.Name}}
+}
+
+func describeNameMismatch(nm NameMismatch) (Finding, suppressionTarget) {
+	return Finding{Check: CheckNameMismatches, VLANID: nm.VxLAN.ID, Detail: nm.VxLAN.Name},
+		suppressionTarget{names: []string{nm.VxLAN.Name}}
+}
+
+func describeWrongPrefix(wp WrongPrefix) (Finding, suppressionTarget) {
+	return Finding{Check: CheckWrongPrefixes, VLANID: wp.VLAN.ID, Detail: wp.Prefix.Prefix},
+		suppressionTarget{names: []string{wp.VLAN.Name}, prefix: wp.Prefix.Prefix}
+}
+
+func describeStaleVLAN(vlan models.NetboxVLAN) (Finding, suppressionTarget) {
+	return Finding{Check: CheckStaleVLANs, VLANID: vlan.VID, Detail: vlan.Name},
+		suppressionTarget{names: []string{vlan.Name}}
+}
+
+func describeDuplicate(d Duplicate) (Finding, suppressionTarget) {
+	var names []string
+	for _, vlan := range d.NetboxVLANs {
+		names = append(names, vlan.Name)
+	}
+	for _, vxlan := range d.NAMVxLANs {
+		names = append(names, vxlan.Name)
+	}
+	return Finding{Check: CheckDuplicates, VLANID: d.VID, Detail: d.Detail()},
+		suppressionTarget{names: names}
+}
+
+func describePrefixIssue(issue PrefixIssue) (Finding, suppressionTarget) {
+	var names []string
+	if issue.VLAN != nil {
+		names = append(names, issue.VLAN.Name)
+	}
+	return Finding{Check: CheckPrefixIntegrity, VLANID: issue.vid(), Detail: issue.Detail()},
+		suppressionTarget{names: names, prefix: issue.Prefix.Prefix}
+}
+
+func describeSubnetMismatch(m SubnetMismatch) (Finding, suppressionTarget) {
+	target := suppressionTarget{names: []string{m.VxLAN.Name}}
+	if m.Subnet != nil {
+		target.prefix = m.Subnet.Prefix
+	} else {
+		target.prefix = m.Prefix.Prefix
+	}
+	return Finding{Check: CheckSubnetMismatches, VLANID: m.VxLAN.ID, Detail: m.Detail()}, target
+}
+
+func describePrefixOverlap(o PrefixOverlap) (Finding, suppressionTarget) {
+	return Finding{Check: CheckPrefixOverlaps, Detail: o.Detail()},
+		suppressionTarget{prefix: o.Prefix.Prefix}
+}
+
+func describeWrongVRF(wv WrongVRF) (Finding, suppressionTarget) {
+	return Finding{Check: CheckWrongVRFs, VLANID: wv.VxLAN.ID, Detail: wv.Prefix.Prefix},
+		suppressionTarget{names: []string{wv.VxLAN.Name}, prefix: wv.Prefix.Prefix}
+}
+
+func describePolicyViolation(pv PolicyViolation) (Finding, suppressionTarget) {
+	return Finding{Check: CheckVLANPolicies, VLANID: pv.VLAN.VID, Detail: pv.Detail()},
+		suppressionTarget{names: []string{pv.VLAN.Name}}
+}
+
+// Fingerprint returns a short hash identifying the set of findings. It does
+// not depend on the order of the findings, so two runs with the same
+// findings have the same fingerprint.
+func (r *Result) Fingerprint() string {
+	var keys []string
+	for _, f := range r.Findings() {
+		keys = append(keys, f.Key())
+	}
+	sort.Strings(keys)
+
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,53 @@
+package checker
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+// migration is a migration rule prepared for matching VLAN names
+type migration struct {
+	rule   config.MigrationRule
+	source *regexp.Regexp
+}
+
+// migrationsFor returns the migration rules that apply to an infra. Rules
+// with an invalid regex are skipped, they are rejected when the config is
+// loaded.
+func migrationsFor(rules []config.MigrationRule, infra string) []migration {
+	var migrations []migration
+	for _, rule := range rules {
+		if rule.Infra != "" && rule.Infra != infra {
+			continue
+		}
+
+		m := migration{rule: rule}
+		if rule.Regex {
+			source, err := regexp.Compile(rule.Source)
+			if err != nil {
+				continue
+			}
+			m.source = source
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations
+}
+
+// oldName returns the name a Netbox VLAN is expected to have in NAM if it
+// was renamed by the migration, and false if the rule does not match
+func (m migration) oldName(name string) (string, bool) {
+	if m.source != nil {
+		if !m.source.MatchString(name) {
+			return "", false
+		}
+		return m.source.ReplaceAllString(name, m.rule.Target), true
+	}
+
+	if !strings.Contains(name, m.rule.Source) {
+		return "", false
+	}
+	return strings.ReplaceAll(name, m.rule.Source, m.rule.Target), true
+}
@@ -0,0 +1,123 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+// defaultSeparators are the characters folded by a fold_separators
+// normalizer without a value
+const defaultSeparators = "-_ ."
+
+// nameMatcher normalises and compares VLAN names according to the name
+// comparison settings
+type nameMatcher struct {
+	normalizers []func(string) string
+	score       bool
+}
+
+// newNameMatcher prepares the normalizers of the settings. Invalid
+// normalizers are skipped, they are rejected when the config is loaded.
+func newNameMatcher(cfg config.NameComparison) *nameMatcher {
+	m := &nameMatcher{score: cfg.Similarity}
+	for _, n := range cfg.Normalizers {
+		if normalize := newNormalizer(n); normalize != nil {
+			m.normalizers = append(m.normalizers, normalize)
+		}
+	}
+	return m
+}
+
+// newNormalizer returns the function applying a normalizer, or nil if the
+// normalizer is invalid
+func newNormalizer(n config.NameNormalizer) func(string) string {
+	switch n.Type {
+	case config.NormalizerFoldSeparators:
+		separators := n.Value
+		if separators == "" {
+			separators = defaultSeparators
+		}
+		var class strings.Builder
+		for _, r := range separators {
+			fmt.Fprintf(&class, `\x{%x}`, r)
+		}
+		pattern := regexp.MustCompile("[" + class.String() + "]+")
+		return func(name string) string {
+			return pattern.ReplaceAllString(name, "-")
+		}
+	case config.NormalizerStripPrefix:
+		prefix := strings.ToLower(n.Value)
+		return func(name string) string {
+			return strings.TrimPrefix(name, prefix)
+		}
+	case config.NormalizerStripSuffix:
+		suffix := strings.ToLower(n.Value)
+		return func(name string) string {
+			return strings.TrimSuffix(name, suffix)
+		}
+	case config.NormalizerRegexReplace:
+		// The name is already lowercased, so match the pattern regardless
+		// of case
+		pattern, err := regexp.Compile("(?i)" + n.Pattern)
+		if err != nil {
+			return nil
+		}
+		return func(name string) string {
+			return pattern.ReplaceAllString(name, n.Replacement)
+		}
+	default:
+		return nil
+	}
+}
+
+// normalize normalizes a name for comparison
+func (m *nameMatcher) normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, normalize := range m.normalizers {
+		name = normalize(name)
+	}
+	return name
+}
+
+// equal reports whether two names are the same after normalisation
+func (m *nameMatcher) equal(a, b string) bool {
+	return m.normalize(a) == m.normalize(b)
+}
+
+// similarity returns how similar two normalised names are, from 0 for
+// completely different to 1 for identical, based on their Levenshtein
+// distance
+func (m *nameMatcher) similarity(a, b string) float64 {
+	ra, rb := []rune(m.normalize(a)), []rune(m.normalize(b))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the number of single character insertions, deletions
+// and substitutions needed to turn a into b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,81 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		normalizers []config.NameNormalizer
+		in          string
+		want        string
+	}{
+		{"lowercased and trimmed", nil, "  App_100 ", "app_100"},
+		{"default separators", []config.NameNormalizer{{Type: config.NormalizerFoldSeparators}}, "app_100 .x", "app-100-x"},
+		{"custom separators", []config.NameNormalizer{{Type: config.NormalizerFoldSeparators, Value: "_"}}, "app_100.x", "app-100.x"},
+		{"strip prefix ignores case", []config.NameNormalizer{{Type: config.NormalizerStripPrefix, Value: "VL-"}}, "VL-app", "app"},
+		{"strip prefix only at the start", []config.NameNormalizer{{Type: config.NormalizerStripPrefix, Value: "vl-"}}, "app-vl-1", "app-vl-1"},
+		{"strip suffix", []config.NameNormalizer{{Type: config.NormalizerStripSuffix, Value: "-OLD"}}, "app-old", "app"},
+		{"regex replace", []config.NameNormalizer{{Type: config.NormalizerRegexReplace, Pattern: `^(\w+)-\d+$`, Replacement: "$1"}}, "app-100", "app"},
+		{"regex replace ignores case", []config.NameNormalizer{{Type: config.NormalizerRegexReplace, Pattern: `^PROD-`, Replacement: ""}}, "Prod-App", "app"},
+		{"normalizers run in order", []config.NameNormalizer{
+			{Type: config.NormalizerFoldSeparators},
+			{Type: config.NormalizerStripSuffix, Value: "-old"},
+		}, "app_old", "app"},
+		{"order matters", []config.NameNormalizer{
+			{Type: config.NormalizerStripSuffix, Value: "-old"},
+			{Type: config.NormalizerFoldSeparators},
+		}, "app_old", "app-old"},
+		{"invalid normalizers are skipped", []config.NameNormalizer{{Type: "unknown"}, {Type: config.NormalizerRegexReplace, Pattern: "("}}, "App", "app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newNameMatcher(config.NameComparison{Normalizers: tt.normalizers})
+			if got := m.normalize(tt.in); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	m := newNameMatcher(config.NameComparison{})
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"app-100", "APP-100", 1},
+		{"", "", 1},
+		{"abcd", "wxyz", 0},
+		{"db-200", "db-old", 0.5},
+		{"app", "", 0},
+		{"blåbær", "blabær", 5.0 / 6},
+	}
+	for _, tt := range tests {
+		if got := m.similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"æøå", "aøa", 2},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
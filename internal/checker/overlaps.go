@@ -0,0 +1,103 @@
+package checker
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// Kinds of prefix overlaps
+const (
+	OverlapDuplicate  = "duplicate"
+	OverlapCrossInfra = "cross_infra"
+	OverlapUnnested   = "overlap"
+)
+
+// PrefixOverlap is a pair of overlapping prefixes in the same site and VRF.
+// Prefix is the larger of the two, or the first for duplicates.
+type PrefixOverlap struct {
+	Kind   string
+	Prefix models.NetboxPrefix
+	Other  models.NetboxPrefix
+}
+
+// Detail describes the overlap for the overlap's finding
+func (o PrefixOverlap) Detail() string {
+	return o.Kind + " " + o.Prefix.Prefix + " " + o.Other.Prefix
+}
+
+// parsedPrefix is a Netbox prefix with its parsed network
+type parsedPrefix struct {
+	prefix  models.NetboxPrefix
+	network netip.Prefix
+}
+
+// checkPrefixOverlaps finds overlapping prefixes of the site within each
+// VRF. Each pair is reported by one check of the site: the check of the
+// smaller prefix's infra, else of the larger prefix's infra, else the
+// site's first check (see owner). site holds the infras checked in the
+// site. Exact duplicates are always reported, and so are overlaps between
+// prefixes with different infra values, also when one of them is a
+// container. Other overlaps are reported unless the larger prefix is a
+// container of the smaller one. Prefixes that do not parse are skipped.
+func checkPrefixOverlaps(prefixes []models.NetboxPrefix, infra string, site []string) []PrefixOverlap {
+	byVRF := make(map[int][]parsedPrefix)
+	for _, prefix := range prefixes {
+		network, err := netip.ParsePrefix(prefix.Prefix)
+		if err != nil {
+			continue
+		}
+		vrf := 0
+		if prefix.VRF != nil {
+			vrf = prefix.VRF.ID
+		}
+		byVRF[vrf] = append(byVRF[vrf], parsedPrefix{prefix: prefix, network: network.Masked()})
+	}
+
+	var overlaps []PrefixOverlap
+	for _, vrf := range sortedKeys(byVRF) {
+		group := byVRF[vrf]
+		sort.SliceStable(group, func(i, j int) bool {
+			if c := group[i].network.Addr().Compare(group[j].network.Addr()); c != 0 {
+				return c < 0
+			}
+			return group[i].network.Bits() < group[j].network.Bits()
+		})
+
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				if !a.network.Overlaps(b.network) {
+					continue
+				}
+				if owner(site, b.prefix.GetInfra(), a.prefix.GetInfra()) != infra {
+					continue
+				}
+				if kind := overlapKind(a, b); kind != "" {
+					overlaps = append(overlaps, PrefixOverlap{Kind: kind, Prefix: a.prefix, Other: b.prefix})
+				}
+			}
+		}
+	}
+	return overlaps
+}
+
+// overlapKind classifies two overlapping prefixes, where a is the larger
+// or equal one. It returns "" if the overlap is a proper container and
+// child relationship.
+func overlapKind(a, b parsedPrefix) string {
+	if a.network == b.network {
+		return OverlapDuplicate
+	}
+
+	infraA, infraB := a.prefix.GetInfra(), b.prefix.GetInfra()
+	if infraA != "" && infraB != "" && infraA != infraB {
+		return OverlapCrossInfra
+	}
+
+	if a.prefix.GetStatus() == models.PrefixStatusContainer {
+		return ""
+	}
+	return OverlapUnnested
+}
@@ -0,0 +1,155 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckPrefixOverlaps(t *testing.T) {
+	// prefix returns a prefix without a VLAN, a container if the status is
+	// set, in the VRF with the ID if not 0
+	prefix := func(id int, cidr, infra, status string, vrf int) models.NetboxPrefix {
+		p := testPrefix(id, cidr, nil, infra)
+		if status != "" {
+			p.Status = &models.Choice{Value: status}
+		}
+		if vrf != 0 {
+			p.VRF = &models.VRFReference{ID: vrf, Name: "vrf"}
+		}
+		return p
+	}
+	const container = models.PrefixStatusContainer
+
+	tests := []struct {
+		name     string
+		prefixes []models.NetboxPrefix
+		// site are the infras checked in the site, only prod if nil
+		site []string
+		want []string
+	}{
+		{
+			name:     "disjoint",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/24", "prod", "", 0), prefix(2, "10.0.1.0/24", "prod", "", 0)},
+		},
+		{
+			name:     "duplicate",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/24", "prod", "", 0), prefix(2, "10.0.0.0/24", "prod", "", 0)},
+			want:     []string{"duplicate 10.0.0.0/24 10.0.0.0/24"},
+		},
+		{
+			name:     "duplicate containers",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/16", "prod", container, 0), prefix(2, "10.0.0.0/16", "prod", container, 0)},
+			want:     []string{"duplicate 10.0.0.0/16 10.0.0.0/16"},
+		},
+		{
+			name:     "nested in a container",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.1.0/24", "prod", "", 0), prefix(2, "10.0.0.0/16", "prod", container, 0)},
+		},
+		{
+			name:     "nested in a prefix that is not a container",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.1.0/24", "prod", "", 0), prefix(2, "10.0.0.0/16", "prod", "", 0)},
+			want:     []string{"overlap 10.0.0.0/16 10.0.1.0/24"},
+		},
+		{
+			name:     "network written with host bits",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.1/24", "prod", "", 0), prefix(2, "10.0.0.0/24", "prod", "", 0)},
+			want:     []string{"duplicate 10.0.0.1/24 10.0.0.0/24"},
+		},
+		{
+			name:     "different infra",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/25", "prod", "", 0), prefix(2, "10.0.0.0/24", "test", "", 0)},
+			want:     []string{"cross_infra 10.0.0.0/24 10.0.0.0/25"},
+		},
+		{
+			name:     "different infra owned by the smaller prefix",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/25", "test", "", 0), prefix(2, "10.0.0.0/24", "prod", "", 0)},
+			site:     []string{"prod", "test"},
+		},
+		{
+			name:     "smaller prefix of an infra without a check",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/25", "tset", "", 0), prefix(2, "10.0.0.0/24", "prod", "", 0)},
+			want:     []string{"cross_infra 10.0.0.0/24 10.0.0.0/25"},
+		},
+		{
+			name:     "container of another infra",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.1.0/24", "prod", "", 0), prefix(2, "10.0.0.0/16", "test", container, 0)},
+			want:     []string{"cross_infra 10.0.0.0/16 10.0.1.0/24"},
+		},
+		{
+			name:     "in a container without infra",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.1.0/24", "prod", "", 0), prefix(2, "10.0.0.0/16", "", container, 0)},
+		},
+		{
+			name:     "smaller prefix without infra",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.1.0/24", "", "", 0), prefix(2, "10.0.0.0/16", "prod", "", 0)},
+			want:     []string{"overlap 10.0.0.0/16 10.0.1.0/24"},
+		},
+		{
+			name:     "different VRF",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/24", "prod", "", 0), prefix(2, "10.0.0.0/24", "prod", "", 7)},
+		},
+		{
+			name:     "same VRF",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/24", "prod", "", 7), prefix(2, "10.0.0.0/25", "prod", "", 7)},
+			want:     []string{"overlap 10.0.0.0/24 10.0.0.0/25"},
+		},
+		{
+			name:     "neither of the infra",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/24", "test", "", 0), prefix(2, "10.0.0.0/24", "test", "", 0)},
+			site:     []string{"prod", "test"},
+		},
+		{
+			name:     "neither infra with a check",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/24", "test", "", 0), prefix(2, "10.0.0.0/24", "", "", 0)},
+			want:     []string{"duplicate 10.0.0.0/24 10.0.0.0/24"},
+		},
+		{
+			name:     "neither infra with a check, reported by the first check",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/24", "dev", "", 0), prefix(2, "10.0.0.0/24", "dev", "", 0)},
+			site:     []string{"test", "prod"},
+		},
+		{
+			name:     "invalid prefix",
+			prefixes: []models.NetboxPrefix{prefix(1, "10.0.0.0/33", "prod", "", 0), prefix(2, "10.0.0.0/24", "prod", "", 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := tt.site
+			if site == nil {
+				site = []string{"prod"}
+			}
+			if got := details(checkPrefixOverlaps(tt.prefixes, "prod", site)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("overlaps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPrefixOverlapsReportedOnce(t *testing.T) {
+	prefixes := []models.NetboxPrefix{
+		testPrefix(1, "10.0.0.0/16", nil, "prod"),
+		testPrefix(2, "10.0.1.0/24", nil, "test"),
+		testPrefix(3, "10.0.2.0/24", nil, "prod"),
+		testPrefix(4, "10.0.2.0/24", nil, "test"),
+		testPrefix(5, "10.1.3.0/25", nil, "dev"),
+		testPrefix(6, "10.1.3.0/24", nil, ""),
+	}
+
+	var got []string
+	for _, infra := range []string{"prod", "test"} {
+		got = append(got, details(checkPrefixOverlaps(prefixes, infra, []string{"prod", "test"}))...)
+	}
+	want := []string{
+		"overlap 10.0.0.0/16 10.0.2.0/24",
+		"overlap 10.1.3.0/24 10.1.3.0/25",
+		"cross_infra 10.0.0.0/16 10.0.1.0/24",
+		"cross_infra 10.0.0.0/16 10.0.2.0/24",
+		"duplicate 10.0.2.0/24 10.0.2.0/24",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("overlaps of both checks = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package checker
+
+import (
+	"slices"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// Requirements of a VLAN policy
+const (
+	PolicyStatus = "status"
+	PolicyTenant = "tenant"
+	PolicyRole   = "role"
+	PolicyGroup  = "group"
+)
+
+// PolicyViolation is a Netbox VLAN that does not meet a requirement of a
+// VLAN policy
+type PolicyViolation struct {
+	VLAN models.NetboxVLAN
+	// Requirement is the requirement that is not met
+	Requirement string
+	// Actual is the VLAN's value, "" if not set
+	Actual string
+	// Allowed are the allowed values, empty for PolicyTenant
+	Allowed []string
+}
+
+// Detail describes the violation for the violation's finding
+func (p PolicyViolation) Detail() string {
+	return p.Requirement + " " + p.VLAN.Name
+}
+
+// checkVLANPolicies checks the Netbox VLANs of the DC's VxLANs against the
+// VLAN policies of the infra. A VLAN is reported once per requirement even
+// if several policies are violated.
+func checkVLANPolicies(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN, policies []config.VLANPolicy, infra string) []PolicyViolation {
+	vxlanIDs := make(map[int]bool)
+	for _, vxlan := range dcVxLANs {
+		vxlanIDs[vxlan.ID] = true
+	}
+
+	var violations []PolicyViolation
+	reported := make(map[string]bool)
+	for _, policy := range policies {
+		if policy.Infra != "" && policy.Infra != infra {
+			continue
+		}
+
+		for _, vlan := range infraVLANs {
+			if !vxlanIDs[vlan.VID] {
+				continue
+			}
+			for _, v := range policyViolations(policy, vlan) {
+				finding, _ := describePolicyViolation(v)
+				if !reported[finding.Key()] {
+					reported[finding.Key()] = true
+					violations = append(violations, v)
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// policyViolations returns the requirements of a policy a VLAN does not meet
+func policyViolations(policy config.VLANPolicy, vlan models.NetboxVLAN) []PolicyViolation {
+	var violations []PolicyViolation
+
+	if len(policy.Statuses) > 0 && !slices.Contains(policy.Statuses, vlan.GetStatus()) {
+		violations = append(violations, PolicyViolation{VLAN: vlan, Requirement: PolicyStatus, Actual: vlan.GetStatus(), Allowed: policy.Statuses})
+	}
+	if policy.RequireTenant && vlan.Tenant == nil {
+		violations = append(violations, PolicyViolation{VLAN: vlan, Requirement: PolicyTenant})
+	}
+	if len(policy.Roles) > 0 && !slices.ContainsFunc(policy.Roles, vlan.Role.Is) {
+		violations = append(violations, PolicyViolation{VLAN: vlan, Requirement: PolicyRole, Actual: vlan.Role.String(), Allowed: policy.Roles})
+	}
+	if len(policy.Groups) > 0 && !slices.ContainsFunc(policy.Groups, vlan.Group.Is) {
+		violations = append(violations, PolicyViolation{VLAN: vlan, Requirement: PolicyGroup, Actual: vlan.Group.String(), Allowed: policy.Groups})
+	}
+
+	return violations
+}
@@ -0,0 +1,49 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckVLANPolicies(t *testing.T) {
+	vxlans := []models.NAMVxLAN{testVxLAN(100, "app-100", "dc1")}
+
+	vlan := testVLAN(1, 100, "app-100", "prod")
+	vlan.Status = &models.Choice{Value: "active"}
+	vlan.Tenant = &models.NestedObject{ID: 1, Name: "Apps", Slug: "apps"}
+	vlan.Role = &models.NestedObject{ID: 1, Name: "Production", Slug: "production"}
+	vlan.Group = &models.NestedObject{ID: 1, Name: "DC1", Slug: "dc1"}
+	bare := testVLAN(2, 100, "app-100", "prod")
+	other := testVLAN(3, 300, "web-300", "prod")
+
+	tests := []struct {
+		name     string
+		policies []config.VLANPolicy
+		vlan     models.NetboxVLAN
+		want     []string
+	}{
+		{"meets every requirement", []config.VLANPolicy{{Statuses: []string{"active"}, RequireTenant: true, Roles: []string{"Production"}, Groups: []string{"dc1"}}}, vlan, nil},
+		{"wrong status", []config.VLANPolicy{{Statuses: []string{"reserved"}}}, vlan, []string{"status active"}},
+		{"role by slug", []config.VLANPolicy{{Roles: []string{"production"}}}, vlan, nil},
+		{"wrong group", []config.VLANPolicy{{Groups: []string{"dc2"}}}, vlan, []string{"group DC1"}},
+		{"nothing set", []config.VLANPolicy{{Statuses: []string{"active"}, RequireTenant: true, Roles: []string{"prod"}, Groups: []string{"dc1"}}}, bare, []string{"status ", "tenant ", "role ", "group "}},
+		{"policy of another infra", []config.VLANPolicy{{Infra: "test", RequireTenant: true}}, bare, nil},
+		{"policy of the infra", []config.VLANPolicy{{Infra: "prod", RequireTenant: true}}, bare, []string{"tenant "}},
+		{"reported once per requirement", []config.VLANPolicy{{RequireTenant: true}, {Infra: "prod", RequireTenant: true}}, bare, []string{"tenant "}},
+		{"VLAN without a VxLAN", []config.VLANPolicy{{RequireTenant: true}}, other, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range checkVLANPolicies(vxlans, []models.NetboxVLAN{tt.vlan}, tt.policies, "prod") {
+				got = append(got, v.Requirement+" "+v.Actual)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package checker
+
+import (
+	"slices"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// Kinds of prefix integrity issues
+const (
+	PrefixNoVLAN        = "no_vlan"
+	PrefixForeignVLAN   = "foreign_vlan"
+	PrefixInfraMismatch = "infra_mismatch"
+)
+
+// PrefixIssue is a prefix of the site with a missing or inconsistent VLAN
+type PrefixIssue struct {
+	Kind   string
+	Prefix models.NetboxPrefix
+	// VLAN is the VLAN of the prefix, nil for PrefixNoVLAN. For
+	// PrefixForeignVLAN only ID, VID and Name are known.
+	VLAN *models.NetboxVLAN
+}
+
+// Detail describes the issue for the issue's finding
+func (p PrefixIssue) Detail() string {
+	return p.Prefix.Prefix + " " + p.Kind
+}
+
+// vid returns the VID of the issue's VLAN, or 0 if it has none
+func (p PrefixIssue) vid() int {
+	if p.VLAN == nil {
+		return 0
+	}
+	return p.VLAN.VID
+}
+
+// checkPrefixIntegrity finds the site's prefixes for the infra that have no
+// VLAN or a VLAN outside the site, and prefixes whose infra differs from
+// their VLAN's when either of them is checked in the site. A mismatch is
+// reported by the check of the VLAN's infra, else of the prefix's infra, so
+// the checks of a shared site report it once and the fix can follow the
+// VLAN. Container prefixes are skipped, and so are prefixes already
+// reported as wrong prefixes.
+func checkPrefixIntegrity(netboxVLANs []models.NetboxVLAN, prefixes []models.NetboxPrefix, infra string, site []string, wrong []WrongPrefix) []PrefixIssue {
+	siteVLANs := make(map[int]models.NetboxVLAN)
+	for _, vlan := range netboxVLANs {
+		siteVLANs[vlan.ID] = vlan
+	}
+
+	reported := make(map[int]bool)
+	for _, wp := range wrong {
+		reported[wp.Prefix.ID] = true
+	}
+
+	var issues []PrefixIssue
+	for _, prefix := range prefixes {
+		if prefix.GetStatus() == models.PrefixStatusContainer || reported[prefix.ID] {
+			continue
+		}
+
+		if prefix.VLAN == nil {
+			if prefix.GetInfra() == infra {
+				issues = append(issues, PrefixIssue{Kind: PrefixNoVLAN, Prefix: prefix})
+			}
+			continue
+		}
+
+		vlan, ok := siteVLANs[prefix.VLAN.ID]
+		if !ok {
+			if prefix.GetInfra() == infra {
+				issues = append(issues, PrefixIssue{
+					Kind:   PrefixForeignVLAN,
+					Prefix: prefix,
+					VLAN:   &models.NetboxVLAN{ID: prefix.VLAN.ID, VID: prefix.VLAN.VID, Name: prefix.VLAN.Name},
+				})
+			}
+			continue
+		}
+
+		vlanInfra, prefixInfra := vlan.GetInfra(), prefix.GetInfra()
+		checked := slices.Contains(site, vlanInfra) || slices.Contains(site, prefixInfra)
+		if checked && vlanInfra != prefixInfra && owner(site, vlanInfra, prefixInfra) == infra {
+			issues = append(issues, PrefixIssue{Kind: PrefixInfraMismatch, Prefix: prefix, VLAN: &vlan})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckPrefixIntegrity(t *testing.T) {
+	vlans := []models.NetboxVLAN{
+		testVLAN(1, 100, "app-100", "prod"),
+		testVLAN(2, 200, "test-200", "test"),
+	}
+	container := testPrefix(9, "10.0.0.0/16", nil, "test")
+	container.Status = &models.Choice{Value: models.PrefixStatusContainer}
+
+	// site is the infras checked in the site, only "prod" if nil
+	tests := []struct {
+		name   string
+		prefix models.NetboxPrefix
+		site   []string
+		want   []string
+	}{
+		{"consistent", testPrefix(1, "10.0.1.0/24", &vlans[0], "prod"), nil, nil},
+		{"no VLAN", testPrefix(2, "10.0.2.0/24", nil, "prod"), nil, []string{"10.0.2.0/24 no_vlan"}},
+		{"no VLAN of another infra", testPrefix(3, "10.0.3.0/24", nil, "test"), nil, nil},
+		{"VLAN outside the site", testPrefix(4, "10.0.4.0/24", &models.NetboxVLAN{ID: 99, VID: 999}, "prod"), nil, []string{"10.0.4.0/24 foreign_vlan"}},
+		{"VLAN of the infra", testPrefix(5, "10.0.5.0/24", &vlans[0], "test"), nil, []string{"10.0.5.0/24 infra_mismatch"}},
+		{"prefix of the infra", testPrefix(6, "10.0.6.0/24", &vlans[1], "prod"), nil, []string{"10.0.6.0/24 infra_mismatch"}},
+		{"neither of the infra", testPrefix(7, "10.0.7.0/24", &vlans[1], "dev"), nil, nil},
+		{"container", container, nil, nil},
+		{"VLAN of the infra in a shared site", testPrefix(5, "10.0.5.0/24", &vlans[0], "test"), []string{"prod", "test"}, []string{"10.0.5.0/24 infra_mismatch"}},
+		{"VLAN of another checked infra", testPrefix(6, "10.0.6.0/24", &vlans[1], "prod"), []string{"prod", "test"}, nil},
+		{"VLAN of an unchecked infra", testPrefix(7, "10.0.7.0/24", &vlans[1], "prod"), []string{"prod", "dev"}, []string{"10.0.7.0/24 infra_mismatch"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := tt.site
+			if site == nil {
+				site = []string{"prod"}
+			}
+			var got []string
+			for _, issue := range checkPrefixIntegrity(vlans, []models.NetboxPrefix{tt.prefix}, "prod", site, nil) {
+				got = append(got, issue.Detail())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPrefixIntegrityReportedOnce(t *testing.T) {
+	vlans := []models.NetboxVLAN{
+		testVLAN(1, 100, "app-100", "prod"),
+		testVLAN(2, 200, "test-200", "test"),
+	}
+	prefixes := []models.NetboxPrefix{
+		testPrefix(1, "10.0.1.0/24", &vlans[0], "test"),
+		testPrefix(2, "10.0.2.0/24", &vlans[1], "prod"),
+		testPrefix(3, "10.0.3.0/24", &vlans[0], "dev"),
+	}
+	site := []string{"prod", "test"}
+
+	got := make(map[string]string)
+	for _, infra := range site {
+		for _, issue := range checkPrefixIntegrity(vlans, prefixes, infra, site, nil) {
+			if previous, ok := got[issue.Detail()]; ok {
+				t.Errorf("%s reported by %s and %s", issue.Detail(), previous, infra)
+			}
+			got[issue.Detail()] = infra
+		}
+	}
+
+	// Each mismatch is reported by the check of the VLAN's infra
+	want := map[string]string{
+		"10.0.1.0/24 infra_mismatch": "prod",
+		"10.0.2.0/24 infra_mismatch": "test",
+		"10.0.3.0/24 infra_mismatch": "prod",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reported by = %v, want %v", got, want)
+	}
+}
+
+func TestCheckPrefixIntegritySkipsWrongPrefixes(t *testing.T) {
+	prefix := testPrefix(1, "10.0.1.0/24", nil, "prod")
+	wrong := []WrongPrefix{{Prefix: prefix}}
+	if issues := checkPrefixIntegrity(nil, []models.NetboxPrefix{prefix}, "prod", []string{"prod"}, wrong); len(issues) != 0 {
+		t.Errorf("issues = %+v, want none for a wrong prefix", issues)
+	}
+}
+
+func testVLAN(id, vid int, name, infra string) models.NetboxVLAN {
+	return models.NetboxVLAN{ID: id, VID: vid, Name: name, CustomFields: map[string]interface{}{"infra": infra}}
+}
+
+// testPrefix returns a prefix on vlan, or without a VLAN if vlan is nil
+func testPrefix(id int, cidr string, vlan *models.NetboxVLAN, infra string) models.NetboxPrefix {
+	prefix := models.NetboxPrefix{ID: id, Prefix: cidr, CustomFields: map[string]interface{}{"infra": infra}}
+	if vlan != nil {
+		prefix.VLAN = &models.VLANReference{ID: vlan.ID, VID: vlan.VID, Name: vlan.Name}
+	}
+	return prefix
+}
@@ -0,0 +1,110 @@
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// StretchedVxLAN is a VxLAN that belongs to several NAM containers and is
+// registered in Netbox for some of the checked DCs but not for others
+type StretchedVxLAN struct {
+	VxLAN models.NAMVxLAN
+	// Present are the checked DCs with the VLAN in Netbox
+	Present []string
+	// Missing are the checked DCs without the VLAN in Netbox
+	Missing []string
+	// Unchecked are the containers of the VxLAN without a successful check
+	Unchecked []string
+}
+
+// stretchedPresence records whether a stretched VxLAN of a DC has a VLAN
+// with its ID in Netbox
+type stretchedPresence struct {
+	vxlan    models.NAMVxLAN
+	inNetbox bool
+}
+
+// checkStretchedPresence records the presence in Netbox of the DC's
+// VxLANs that belong to more than one container
+func checkStretchedPresence(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN) []stretchedPresence {
+	vids := make(map[int]bool)
+	for _, vlan := range infraVLANs {
+		vids[vlan.VID] = true
+	}
+
+	var presence []stretchedPresence
+	for _, vxlan := range dcVxLANs {
+		if len(vxlan.Containers) > 1 {
+			presence = append(presence, stretchedPresence{vxlan: vxlan, inNetbox: vids[vxlan.ID]})
+		}
+	}
+	return presence
+}
+
+// CheckStretchedVxLANs compares the stretched VxLANs across the results of
+// a run and returns those missing in Netbox for some, but not all, of the
+// checked DCs they belong to. A DC with several checks has the VLAN if any
+// of its checks found it.
+func CheckStretchedVxLANs(results []*Result) []StretchedVxLAN {
+	checked := make(map[string]bool)
+	vxlans := make(map[int]models.NAMVxLAN)
+	inNetbox := make(map[int]map[string]bool)
+
+	for _, result := range results {
+		checked[result.DCName] = true
+		for _, p := range result.stretched {
+			if _, ok := vxlans[p.vxlan.ID]; !ok {
+				vxlans[p.vxlan.ID] = p.vxlan
+				inNetbox[p.vxlan.ID] = make(map[string]bool)
+			}
+			if p.inNetbox {
+				inNetbox[p.vxlan.ID][result.DCName] = true
+			}
+		}
+	}
+
+	var stretched []StretchedVxLAN
+	for _, id := range sortedKeys(vxlans) {
+		s := StretchedVxLAN{VxLAN: vxlans[id]}
+		for _, dc := range s.VxLAN.ContainerNames() {
+			switch {
+			case !checked[dc]:
+				s.Unchecked = append(s.Unchecked, dc)
+			case inNetbox[id][dc]:
+				s.Present = append(s.Present, dc)
+			default:
+				s.Missing = append(s.Missing, dc)
+			}
+		}
+		if len(s.Present) > 0 && len(s.Missing) > 0 {
+			stretched = append(stretched, s)
+		}
+	}
+	return stretched
+}
+
+// FormatStretchedVxLANs creates the report section for stretched VxLANs
+// missing in Netbox for some of their DCs
+func FormatStretchedVxLANs(stretched []StretchedVxLAN) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(strings.Repeat("=", 75))
+	buf.WriteString("\n")
+	buf.WriteString("Vxlans på tvers av datasentre som mangler i Netbox for noen av dem\n")
+	buf.WriteString(strings.Repeat("=", 75))
+	buf.WriteString("\n")
+	for _, s := range stretched {
+		line := fmt.Sprintf("✗ [NAM VLAN ID %d]: -> %s (finnes i %s, mangler i %s",
+			s.VxLAN.ID, s.VxLAN.Name, strings.Join(s.Present, ", "), strings.Join(s.Missing, ", "))
+		if len(s.Unchecked) > 0 {
+			line += fmt.Sprintf(", ikke sjekket: %s", strings.Join(s.Unchecked, ", "))
+		}
+		buf.WriteString(line + ")\n")
+	}
+	buf.WriteString("\n")
+
+	return buf.String()
+}
@@ -0,0 +1,56 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckStretchedVxLANs(t *testing.T) {
+	stretched := testVxLAN(300, "shared-300", "dc1", "dc2", "dc3")
+	local := testVxLAN(100, "app-100", "dc1")
+	vlans := []models.NetboxVLAN{testVLAN(1, 300, "shared-300", "prod"), testVLAN(2, 100, "app-100", "prod")}
+
+	// check returns the result of a DC, with or without the VLANs
+	check := func(dc string, vlans []models.NetboxVLAN) *Result {
+		return Check(dc, "prod", vlans, nil, []models.NAMVxLAN{stretched, local}, []models.NAMSubnet{}, &config.Config{})
+	}
+
+	tests := []struct {
+		name    string
+		results []*Result
+		want    []StretchedVxLAN
+	}{
+		{
+			name:    "present everywhere",
+			results: []*Result{check("dc1", vlans), check("dc2", vlans), check("dc3", vlans)},
+		},
+		{
+			name:    "missing everywhere",
+			results: []*Result{check("dc1", nil), check("dc2", nil)},
+		},
+		{
+			name:    "missing in one DC",
+			results: []*Result{check("dc1", vlans), check("dc2", nil), check("dc3", vlans)},
+			want:    []StretchedVxLAN{{VxLAN: stretched, Present: []string{"dc1", "dc3"}, Missing: []string{"dc2"}}},
+		},
+		{
+			name:    "DC without a check",
+			results: []*Result{check("dc1", vlans), check("dc2", nil)},
+			want:    []StretchedVxLAN{{VxLAN: stretched, Present: []string{"dc1"}, Missing: []string{"dc2"}, Unchecked: []string{"dc3"}}},
+		},
+		{
+			name:    "any check of a DC",
+			results: []*Result{check("dc1", vlans), check("dc2", nil), check("dc2", vlans)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckStretchedVxLANs(tt.results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stretched = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
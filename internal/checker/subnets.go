@@ -0,0 +1,149 @@
+package checker
+
+import (
+	"net/netip"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// Kinds of subnet mismatches
+const (
+	SubnetMissingInNetbox = "missing_in_netbox"
+	SubnetMissingInNAM    = "missing_in_nam"
+	SubnetCIDRMismatch    = "cidr_mismatch"
+)
+
+// SubnetMismatch is a NAM subnet or Netbox prefix of a VxLAN without a
+// counterpart with the same CIDR on the other side
+type SubnetMismatch struct {
+	Kind  string
+	VxLAN models.NAMVxLAN
+	// Subnet is the NAM subnet, nil for SubnetMissingInNAM
+	Subnet *models.NAMSubnet
+	// Prefix is the Netbox prefix, nil for SubnetMissingInNetbox
+	Prefix *models.NetboxPrefix
+}
+
+// Detail describes the mismatch for the mismatch's finding
+func (m SubnetMismatch) Detail() string {
+	switch m.Kind {
+	case SubnetMissingInNetbox:
+		return m.Kind + " " + m.Subnet.Prefix
+	case SubnetMissingInNAM:
+		return m.Kind + " " + m.Prefix.Prefix
+	default:
+		return m.Kind + " " + m.Subnet.Prefix + " " + m.Prefix.Prefix
+	}
+}
+
+// checkSubnets compares the NAM subnets of each of the DC's VxLANs with the
+// Netbox prefixes on the VLANs with the VxLAN's ID. Subnets and prefixes
+// that only differ in prefix length are reported as CIDR mismatches.
+// VxLANs without a VLAN in Netbox are already reported as misconfigured
+// and skipped.
+func checkSubnets(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN, prefixes []models.NetboxPrefix, subnets []models.NAMSubnet) []SubnetMismatch {
+	var mismatches []SubnetMismatch
+	checked := make(map[int]bool)
+
+	for _, vxlan := range dcVxLANs {
+		if checked[vxlan.ID] {
+			continue
+		}
+		checked[vxlan.ID] = true
+
+		vlanIDs := make(map[int]bool)
+		for _, vlan := range infraVLANs {
+			if vlan.VID == vxlan.ID {
+				vlanIDs[vlan.ID] = true
+			}
+		}
+		if len(vlanIDs) == 0 {
+			continue
+		}
+
+		var netboxSide []models.NetboxPrefix
+		for _, prefix := range prefixes {
+			if prefix.VLAN != nil && vlanIDs[prefix.VLAN.ID] && prefix.GetStatus() != models.PrefixStatusContainer {
+				netboxSide = append(netboxSide, prefix)
+			}
+		}
+
+		var namSide []models.NAMSubnet
+		for _, subnet := range subnets {
+			if subnet.VxLAN != nil && subnet.VxLAN.ID == vxlan.ID {
+				namSide = append(namSide, subnet)
+			}
+		}
+
+		mismatches = append(mismatches, compareSubnets(vxlan, namSide, netboxSide)...)
+	}
+	return mismatches
+}
+
+// compareSubnets pairs the subnets and prefixes of a VxLAN, first by equal
+// CIDR and then by overlap, and reports everything left unpaired
+func compareSubnets(vxlan models.NAMVxLAN, subnets []models.NAMSubnet, prefixes []models.NetboxPrefix) []SubnetMismatch {
+	var mismatches []SubnetMismatch
+	subnetPaired := make([]bool, len(subnets))
+	prefixPaired := make([]bool, len(prefixes))
+
+	for i, subnet := range subnets {
+		for j, prefix := range prefixes {
+			if !prefixPaired[j] && sameCIDR(subnet.Prefix, prefix.Prefix) {
+				subnetPaired[i], prefixPaired[j] = true, true
+				break
+			}
+		}
+	}
+
+	for i := range subnets {
+		if subnetPaired[i] {
+			continue
+		}
+		for j := range prefixes {
+			if !prefixPaired[j] && overlaps(subnets[i].Prefix, prefixes[j].Prefix) {
+				subnetPaired[i], prefixPaired[j] = true, true
+				mismatches = append(mismatches, SubnetMismatch{
+					Kind:   SubnetCIDRMismatch,
+					VxLAN:  vxlan,
+					Subnet: &subnets[i],
+					Prefix: &prefixes[j],
+				})
+				break
+			}
+		}
+	}
+
+	for i := range subnets {
+		if !subnetPaired[i] {
+			mismatches = append(mismatches, SubnetMismatch{Kind: SubnetMissingInNetbox, VxLAN: vxlan, Subnet: &subnets[i]})
+		}
+	}
+	for j := range prefixes {
+		if !prefixPaired[j] {
+			mismatches = append(mismatches, SubnetMismatch{Kind: SubnetMissingInNAM, VxLAN: vxlan, Prefix: &prefixes[j]})
+		}
+	}
+	return mismatches
+}
+
+// sameCIDR reports whether two CIDRs are the same network. CIDRs that do
+// not parse are compared as text.
+func sameCIDR(a, b string) bool {
+	pa, errA := netip.ParsePrefix(a)
+	pb, errB := netip.ParsePrefix(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return pa.Masked() == pb.Masked()
+}
+
+// overlaps reports whether two CIDRs share any address
+func overlaps(a, b string) bool {
+	pa, errA := netip.ParsePrefix(a)
+	pb, errB := netip.ParsePrefix(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return pa.Overlaps(pb)
+}
@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckSubnets(t *testing.T) {
+	vxlans := []models.NAMVxLAN{testVxLAN(100, "app-100", "dc1"), testVxLAN(200, "db-200", "dc1")}
+	vlans := []models.NetboxVLAN{testVLAN(1, 100, "app-100", "prod")}
+	container := testPrefix(9, "10.0.0.0/16", &vlans[0], "prod")
+	container.Status = &models.Choice{Value: models.PrefixStatusContainer}
+
+	tests := []struct {
+		name     string
+		prefixes []models.NetboxPrefix
+		subnets  []models.NAMSubnet
+		want     []string
+	}{
+		{
+			name:     "same CIDR",
+			prefixes: []models.NetboxPrefix{testPrefix(1, "10.0.1.0/24", &vlans[0], "prod")},
+			subnets:  []models.NAMSubnet{testSubnet(1, "10.0.1.0/24", 100)},
+		},
+		{
+			name:     "same network written differently",
+			prefixes: []models.NetboxPrefix{testPrefix(1, "10.0.1.0/24", &vlans[0], "prod")},
+			subnets:  []models.NAMSubnet{testSubnet(1, "10.0.1.5/24", 100)},
+		},
+		{
+			name:     "different length",
+			prefixes: []models.NetboxPrefix{testPrefix(1, "10.0.1.0/24", &vlans[0], "prod")},
+			subnets:  []models.NAMSubnet{testSubnet(1, "10.0.1.0/25", 100)},
+			want:     []string{"cidr_mismatch 10.0.1.0/25 10.0.1.0/24"},
+		},
+		{
+			name:     "exact match is paired before overlap",
+			prefixes: []models.NetboxPrefix{testPrefix(1, "10.0.1.0/24", &vlans[0], "prod"), testPrefix(2, "10.0.1.0/25", &vlans[0], "prod")},
+			subnets:  []models.NAMSubnet{testSubnet(1, "10.0.1.0/24", 100)},
+			want:     []string{"missing_in_nam 10.0.1.0/25"},
+		},
+		{
+			name:    "missing in Netbox",
+			subnets: []models.NAMSubnet{testSubnet(1, "10.0.1.0/24", 100)},
+			want:    []string{"missing_in_netbox 10.0.1.0/24"},
+		},
+		{
+			name:     "missing in NAM",
+			prefixes: []models.NetboxPrefix{testPrefix(1, "10.0.1.0/24", &vlans[0], "prod")},
+			want:     []string{"missing_in_nam 10.0.1.0/24"},
+		},
+		{
+			name:     "subnet of another VxLAN",
+			prefixes: []models.NetboxPrefix{testPrefix(1, "10.0.1.0/24", &vlans[0], "prod")},
+			subnets:  []models.NAMSubnet{testSubnet(1, "10.0.1.0/24", 300)},
+			want:     []string{"missing_in_nam 10.0.1.0/24"},
+		},
+		{
+			name:    "VxLAN without a VLAN is skipped",
+			subnets: []models.NAMSubnet{testSubnet(1, "10.0.2.0/24", 200)},
+		},
+		{
+			name:     "containers are skipped",
+			prefixes: []models.NetboxPrefix{container},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := details(checkSubnets(vxlans, vlans, tt.prefixes, tt.subnets)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subnet mismatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
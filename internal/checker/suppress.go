@@ -0,0 +1,104 @@
+package checker
+
+import (
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+// SuppressedFinding is a finding hidden by a suppression
+type SuppressedFinding struct {
+	Finding     Finding
+	Suppression config.Suppression
+}
+
+// suppressionTarget describes a finding for matching against suppressions
+type suppressionTarget struct {
+	check  string
+	vlanID int
+	names  []string
+	prefix string
+}
+
+// ApplySuppressions moves the findings matching an active suppression in
+// the configuration from the result to Suppressed, and updates
+// HasMismatches and Output accordingly
+func (r *Result) ApplySuppressions(cfg *config.Config, now time.Time) {
+	var rules []config.Suppression
+	for _, s := range cfg.Suppressions {
+		if s.Active(now) && (s.DCName == "" || s.DCName == r.DCName) {
+			rules = append(rules, s)
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	r.MovedVLANs = filterSuppressed(r, rules, r.MovedVLANs, describeMovedVLAN)
+	r.MisconfiguredVLANs = filterSuppressed(r, rules, r.MisconfiguredVLANs, describeMisconfiguredVLAN)
+	r.NameMismatches = filterSuppressed(r, rules, r.NameMismatches, describeNameMismatch)
+	r.WrongPrefixes = filterSuppressed(r, rules, r.WrongPrefixes, describeWrongPrefix)
+	r.StaleVLANs = filterSuppressed(r, rules, r.StaleVLANs, describeStaleVLAN)
+	r.Duplicates = filterSuppressed(r, rules, r.Duplicates, describeDuplicate)
+	r.PrefixIssues = filterSuppressed(r, rules, r.PrefixIssues, describePrefixIssue)
+	r.SubnetMismatches = filterSuppressed(r, rules, r.SubnetMismatches, describeSubnetMismatch)
+	r.PrefixOverlaps = filterSuppressed(r, rules, r.PrefixOverlaps, describePrefixOverlap)
+	r.WrongVRFs = filterSuppressed(r, rules, r.WrongVRFs, describeWrongVRF)
+	r.PolicyViolations = filterSuppressed(r, rules, r.PolicyViolations, describePolicyViolation)
+
+	r.HasMismatches = len(r.Findings()) > 0
+	r.Output = generateOutput(r, cfg)
+}
+
+// filterSuppressed returns the items that are not suppressed and records
+// the suppressed ones in the result
+func filterSuppressed[T any](r *Result, rules []config.Suppression, items []T, describe func(T) (Finding, suppressionTarget)) []T {
+	var kept []T
+	for _, item := range items {
+		finding, target := describe(item)
+		target.check = finding.Check
+		target.vlanID = finding.VLANID
+
+		if rule, ok := matchSuppression(rules, target); ok {
+			r.Suppressed = append(r.Suppressed, SuppressedFinding{
+				Finding:     finding,
+				Suppression: rule,
+			})
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+// matchSuppression returns the first rule matching the target
+func matchSuppression(rules []config.Suppression, target suppressionTarget) (config.Suppression, bool) {
+	for _, rule := range rules {
+		if suppresses(rule, target) {
+			return rule, true
+		}
+	}
+	return config.Suppression{}, false
+}
+
+// suppresses reports whether a rule matches the target. Every criterion
+// set on the rule must match.
+func suppresses(rule config.Suppression, target suppressionTarget) bool {
+	if rule.Check != "" && rule.Check != target.check {
+		return false
+	}
+
+	if rule.VLANID != nil && *rule.VLANID != target.vlanID {
+		return false
+	}
+
+	if !rule.MatchesName(target.names) {
+		return false
+	}
+
+	if rule.PrefixCIDR != "" && (target.prefix == "" || !rule.ContainsPrefix(target.prefix)) {
+		return false
+	}
+
+	return true
+}
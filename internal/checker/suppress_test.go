@@ -0,0 +1,152 @@
+package checker
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// resultWithEveryCheck returns a result with one finding of every check
+// type, all for VxLAN 100 'app-100' and prefix 10.0.0.0/24
+func resultWithEveryCheck() *Result {
+	vxlan := models.NAMVxLAN{ID: 100, Name: "app-100"}
+	vlan := testVLAN(1, 100, "app-100", "prod")
+	prefix := testPrefix(10, "10.0.0.0/24", &vlan, "prod")
+
+	return &Result{
+		DCName:             "dc1",
+		Infra:              "prod",
+		MovedVLANs:         []MovedVLAN{{VxLAN: vxlan, NetboxVLAN: vlan}},
+		MisconfiguredVLANs: []models.NAMVxLAN{vxlan},
+		NameMismatches:     []NameMismatch{{VxLAN: vxlan}},
+		WrongPrefixes:      []WrongPrefix{{VLAN: vxlan, Prefix: prefix}},
+		StaleVLANs:         []models.NetboxVLAN{vlan},
+		Duplicates:         []Duplicate{{Kind: DuplicateVID, VID: 100, NetboxVLANs: []models.NetboxVLAN{vlan, vlan}}},
+		PrefixIssues:       []PrefixIssue{{Kind: PrefixInfraMismatch, Prefix: prefix, VLAN: &vlan}},
+		SubnetMismatches:   []SubnetMismatch{{Kind: SubnetMissingInNAM, VxLAN: vxlan, Prefix: &prefix}},
+		PrefixOverlaps:     []PrefixOverlap{{Kind: OverlapDuplicate, Prefix: prefix, Other: prefix}},
+		WrongVRFs:          []WrongVRF{{VxLAN: vxlan, Prefix: prefix, Expected: "prod"}},
+		PolicyViolations:   []PolicyViolation{{VLAN: vlan, Requirement: PolicyTenant}},
+	}
+}
+
+func TestFindingsCoverEveryCheck(t *testing.T) {
+	var got []string
+	for _, f := range resultWithEveryCheck().Findings() {
+		got = append(got, f.Check)
+	}
+	if !reflect.DeepEqual(got, CheckTypes) {
+		t.Errorf("finding checks = %v, want %v", got, CheckTypes)
+	}
+}
+
+func TestSuppressEveryCheck(t *testing.T) {
+	for _, check := range CheckTypes {
+		t.Run(check, func(t *testing.T) {
+			r := resultWithEveryCheck()
+			cfg := &config.Config{Suppressions: []config.Suppression{{Check: check, Reason: "test"}}}
+			r.ApplySuppressions(cfg, time.Now())
+
+			if len(r.Suppressed) != 1 || r.Suppressed[0].Finding.Check != check {
+				t.Fatalf("suppressed = %+v, want the %s finding", r.Suppressed, check)
+			}
+			for _, f := range r.Findings() {
+				if f.Check == check {
+					t.Errorf("%s finding still reported", check)
+				}
+			}
+			if len(r.Findings()) != len(CheckTypes)-1 {
+				t.Errorf("findings = %d, want %d", len(r.Findings()), len(CheckTypes)-1)
+			}
+		})
+	}
+}
+
+func TestSuppressionExpiry(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		active bool
+	}{
+		{"day before", time.Date(2026, 10, 15, 12, 0, 0, 0, oslo), true},
+		{"start of last day", time.Date(2026, 10, 16, 0, 0, 0, 0, oslo), true},
+		{"end of last day", time.Date(2026, 10, 16, 23, 59, 59, 0, oslo), true},
+		{"day after", time.Date(2026, 10, 17, 0, 0, 0, 0, oslo), false},
+		// 23:30 UTC is already the next day in Oslo
+		{"last day in UTC", time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC), true},
+		{"next day in Oslo", time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC).In(oslo), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := resultWithEveryCheck()
+			cfg := &config.Config{Suppressions: []config.Suppression{
+				{Check: CheckStaleVLANs, NameRegex: "app", Reason: "test", Expires: "2026-10-16"},
+			}}
+			r.ApplySuppressions(cfg, tt.now)
+			if active := len(r.Suppressed) == 1; active != tt.active {
+				t.Errorf("suppressed = %v, want %v", active, tt.active)
+			}
+		})
+	}
+}
+
+func TestSuppressionMatching(t *testing.T) {
+	vlanID := func(id int) *int { return &id }
+
+	tests := []struct {
+		name        string
+		suppression config.Suppression
+		want        []string
+	}{
+		{"prefix within CIDR", config.Suppression{Check: CheckWrongPrefixes, PrefixCIDR: "10.0.0.0/16"}, []string{CheckWrongPrefixes}},
+		{"prefix equal to CIDR", config.Suppression{Check: CheckWrongPrefixes, PrefixCIDR: "10.0.0.0/24"}, []string{CheckWrongPrefixes}},
+		{"prefix wider than CIDR", config.Suppression{Check: CheckWrongPrefixes, PrefixCIDR: "10.0.0.0/25"}, nil},
+		{"prefix outside CIDR", config.Suppression{Check: CheckWrongPrefixes, PrefixCIDR: "10.1.0.0/16"}, nil},
+		{"CIDR on finding without prefix", config.Suppression{Check: CheckStaleVLANs, PrefixCIDR: "10.0.0.0/8"}, nil},
+		{"CIDR for every check", config.Suppression{PrefixCIDR: "10.0.0.0/8"}, []string{
+			CheckWrongPrefixes, CheckPrefixIntegrity, CheckSubnetMismatches, CheckPrefixOverlaps, CheckWrongVRFs,
+		}},
+		{"name regex", config.Suppression{Check: CheckNameMismatches, NameRegex: "^app-1"}, []string{CheckNameMismatches}},
+		{"name regex not matching", config.Suppression{Check: CheckNameMismatches, NameRegex: "^db-"}, nil},
+		{"VLAN ID", config.Suppression{Check: CheckMisconfiguredVLANs, VLANID: vlanID(100)}, []string{CheckMisconfiguredVLANs}},
+		{"other VLAN ID", config.Suppression{Check: CheckMisconfiguredVLANs, VLANID: vlanID(200)}, nil},
+		{"every criterion", config.Suppression{Check: CheckWrongVRFs, VLANID: vlanID(100), NameRegex: "app", PrefixCIDR: "10.0.0.0/8"}, []string{CheckWrongVRFs}},
+		{"one criterion failing", config.Suppression{Check: CheckWrongVRFs, VLANID: vlanID(100), NameRegex: "db", PrefixCIDR: "10.0.0.0/8"}, nil},
+		{"this DC", config.Suppression{DCName: "dc1", Check: CheckStaleVLANs, VLANID: vlanID(100)}, []string{CheckStaleVLANs}},
+		{"other DC", config.Suppression{DCName: "dc2", Check: CheckStaleVLANs, VLANID: vlanID(100)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.suppression.Reason = "test"
+			r := resultWithEveryCheck()
+			r.ApplySuppressions(&config.Config{Suppressions: []config.Suppression{tt.suppression}}, time.Now())
+
+			var got []string
+			for _, sf := range r.Suppressed {
+				got = append(got, sf.Finding.Check)
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("suppressed = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSuppressionChecks(t *testing.T) {
+	// The config cannot import the checker, so it keeps its own list
+	if !reflect.DeepEqual(config.SuppressionChecks, CheckTypes) {
+		t.Errorf("config.SuppressionChecks = %v, want %v", config.SuppressionChecks, CheckTypes)
+	}
+}
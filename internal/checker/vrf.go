@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// WrongVRF represents a prefix on a VxLAN's VLAN that is not in the VRF
+// expected for the VxLAN
+type WrongVRF struct {
+	VxLAN  models.NAMVxLAN
+	Prefix models.NetboxPrefix
+	// Expected is the expected VRF name, config.VRFGlobal for the global
+	// table
+	Expected string
+}
+
+// expectedVRF returns the VRF expected for a VxLAN of the DC: the first
+// matching VRF rule, or else the VxLAN's VRF in NAM. It returns false if
+// neither is set.
+func expectedVRF(rules []config.VRFRule, dcName string, vxlan models.NAMVxLAN) (string, bool) {
+	for _, rule := range rules {
+		if rule.Matches(dcName, vxlan.ID) {
+			return rule.VRF, true
+		}
+	}
+	if vrf := vxlan.GetVRFName(); vrf != "" {
+		return vrf, true
+	}
+	return "", false
+}
+
+// checkWrongVRFs finds prefixes on the VLANs of the DC's VxLANs that are
+// not in the VxLAN's expected VRF. Container prefixes are skipped.
+func checkWrongVRFs(dcVxLANs []models.NAMVxLAN, infraVLANs []models.NetboxVLAN, prefixes []models.NetboxPrefix, rules []config.VRFRule, dcName string) []WrongVRF {
+	var wrong []WrongVRF
+	checked := make(map[int]bool)
+
+	for _, vxlan := range dcVxLANs {
+		if checked[vxlan.ID] {
+			continue
+		}
+		checked[vxlan.ID] = true
+
+		expected, ok := expectedVRF(rules, dcName, vxlan)
+		if !ok {
+			continue
+		}
+
+		vlanIDs := make(map[int]bool)
+		for _, vlan := range infraVLANs {
+			if vlan.VID == vxlan.ID {
+				vlanIDs[vlan.ID] = true
+			}
+		}
+
+		for _, prefix := range prefixes {
+			if prefix.VLAN == nil || !vlanIDs[prefix.VLAN.ID] || prefix.GetStatus() == models.PrefixStatusContainer {
+				continue
+			}
+			if vrfName(prefix) != expected {
+				wrong = append(wrong, WrongVRF{VxLAN: vxlan, Prefix: prefix, Expected: expected})
+			}
+		}
+	}
+	return wrong
+}
+
+// vrfName returns the VRF name of a prefix, config.VRFGlobal for the
+// global table
+func vrfName(prefix models.NetboxPrefix) string {
+	if name := prefix.GetVRFName(); name != "" {
+		return name
+	}
+	return config.VRFGlobal
+}
@@ -0,0 +1,62 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestCheckWrongVRFs(t *testing.T) {
+	withVRF := testVxLAN(100, "app-100", "dc1")
+	withVRF.VRF = &models.VRFReference{ID: 1, Name: "app"}
+	withoutVRF := testVxLAN(200, "db-200", "dc1")
+	vlans := []models.NetboxVLAN{testVLAN(1, 100, "app-100", "prod"), testVLAN(2, 200, "db-200", "prod")}
+
+	// prefix returns a prefix on a VLAN in a VRF, or the global table if vrf
+	// is empty
+	prefix := func(id int, vlan *models.NetboxVLAN, vrf string) models.NetboxPrefix {
+		p := testPrefix(id, "10.0.0.0/24", vlan, "prod")
+		if vrf != "" {
+			p.VRF = &models.VRFReference{ID: id, Name: vrf}
+		}
+		return p
+	}
+	container := prefix(9, &vlans[0], "")
+	container.Status = &models.Choice{Value: models.PrefixStatusContainer}
+
+	tests := []struct {
+		name   string
+		rules  []config.VRFRule
+		prefix models.NetboxPrefix
+		want   string
+	}{
+		{"VRF from NAM", nil, prefix(1, &vlans[0], "app"), ""},
+		{"wrong VRF from NAM", nil, prefix(1, &vlans[0], "other"), "app"},
+		{"global table with a VRF in NAM", nil, prefix(1, &vlans[0], ""), "app"},
+		{"no VRF expected", nil, prefix(1, &vlans[1], "other"), ""},
+		{"rule before NAM", []config.VRFRule{{VRF: "prod"}}, prefix(1, &vlans[0], "app"), "prod"},
+		{"first matching rule", []config.VRFRule{{MinVxLANID: 150, VRF: "high"}, {MaxVxLANID: 150, VRF: "low"}}, prefix(1, &vlans[0], "low"), ""},
+		{"rule of another container", []config.VRFRule{{Container: "dc2", VRF: "prod"}}, prefix(1, &vlans[0], "app"), ""},
+		{"rule for the global table", []config.VRFRule{{VRF: config.VRFGlobal}}, prefix(1, &vlans[1], ""), ""},
+		{"rule for the global table with a VRF", []config.VRFRule{{VRF: config.VRFGlobal}}, prefix(1, &vlans[1], "db"), config.VRFGlobal},
+		{"prefix without a VLAN", nil, prefix(1, nil, "other"), ""},
+		{"container", nil, container, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, w := range checkWrongVRFs([]models.NAMVxLAN{withVRF, withoutVRF}, vlans, []models.NetboxPrefix{tt.prefix}, tt.rules, "dc1") {
+				got = append(got, w.Expected)
+			}
+			var want []string
+			if tt.want != "" {
+				want = []string{tt.want}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected VRFs = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -2,12 +2,13 @@ package client
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"time"
@@ -41,24 +42,75 @@ type ESMRequest struct {
 }
 
 type ESMProperties struct {
-	RequestsOffering   string
-	CreationSource     string
-	RequestedByPerson  string
-	RequestedForPerson string
-	UserOptions        string
-	DisplayLabel       string
-	Description        string
-	PublicScope        string
+	Id                 string `json:",omitempty"`
+	RequestsOffering   string `json:",omitempty"`
+	CreationSource     string `json:",omitempty"`
+	RequestedByPerson  string `json:",omitempty"`
+	RequestedForPerson string `json:",omitempty"`
+	UserOptions        string `json:",omitempty"`
+	DisplayLabel       string `json:",omitempty"`
+	Description        string `json:",omitempty"`
+	PublicScope        string `json:",omitempty"`
+	Status             string `json:",omitempty"`
+	CompletionCode     string `json:",omitempty"`
+	Solution           string `json:",omitempty"`
 }
 
-func NewESMClient(baseURL, username, password string, tenantID int) *ESMClient {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-	}
-	httpClient := &http.Client{
-		Transport: tr,
-		Timeout:   30 * time.Second,
+// ESMExistingRequest is a request already registered in ESM
+type ESMExistingRequest struct {
+	ID           string
+	DisplayLabel string
+	Description  string
+	Status       string
+}
+
+// esmQueryResponse is the response of an ESM entity query
+type esmQueryResponse struct {
+	Entities []struct {
+		Properties struct {
+			Id           json.Number `json:"Id"`
+			DisplayLabel string      `json:"DisplayLabel"`
+			Description  string      `json:"Description"`
+			Status       string      `json:"Status"`
+		} `json:"properties"`
+	} `json:"entities"`
+}
+
+// esmBulkResponse is the response of the ESM bulk endpoint
+type esmBulkResponse struct {
+	Meta struct {
+		CompletionStatus string `json:"completion_status"`
+	} `json:"meta"`
+}
+
+// esmClosedStatuses are the request statuses that mean a request is no
+// longer open
+var esmClosedStatuses = []string{
+	"RequestStatusComplete",
+	"RequestStatusCancelled",
+	"RequestStatusRejected",
+}
+
+// esmFingerprintPattern finds the findings fingerprint in a request description
+var esmFingerprintPattern = regexp.MustCompile(`Fingerprint: ([0-9a-f]+)`)
+
+// ESMDisplayLabel returns the display label of the request for a DC and infra
+func ESMDisplayLabel(dcName, infra string) string {
+	return fmt.Sprintf("Datasenter Infra Check - %s - %s", dcName, infra)
+}
+
+// Fingerprint returns the findings fingerprint stored in the description of
+// the request, or an empty string if it has none
+func (r *ESMExistingRequest) Fingerprint() string {
+	match := esmFingerprintPattern.FindStringSubmatch(r.Description)
+	if match == nil {
+		return ""
 	}
+	return match[1]
+}
+
+func NewESMClient(baseURL, username, password string, tenantID int, retry config.RetryConfig) *ESMClient {
+	httpClient := newHTTPClient("esm", 30*time.Second, retry)
 
 	return &ESMClient{
 		httpClient: httpClient,
@@ -84,7 +136,7 @@ func (c *ESMClient) Authenticate() error {
 
 	request.Header.Add("Content-Type", "application/json")
 
-	res, err := c.httpClient.Do(request)
+	res, err := c.httpClient.Do(markRetryable(request))
 	if err != nil {
 		return err
 	}
@@ -105,17 +157,14 @@ func (c *ESMClient) Authenticate() error {
 
 func (c *ESMClient) CreateRequest(result *checker.Result, dcName, infra string, config *config.Config) ESMRequest {
 
-	// Format preview with HTML line breaks for ESM
-	formattedOutput := strings.ReplaceAll(result.Output, "\n", "<br>")
-
 	properties := ESMProperties{
 		RequestsOffering:   config.ESMOfferingID,
 		CreationSource:     "CreationSourceEss",
 		RequestedByPerson:  config.ESMRequesterID,
 		RequestedForPerson: config.ESMRequesterID,
 		UserOptions:        fmt.Sprintf("{\"complexTypeProperties\":[{\"properties\":{\"Tjeneste_c\":\"%s\",\"Team_c\":\"%s\"}}]}", config.ESMServiceID, config.ESMTeamID),
-		DisplayLabel:       fmt.Sprintf("Datasenter Infra Check - %s - %s", dcName, infra),
-		Description:        formattedOutput,
+		DisplayLabel:       ESMDisplayLabel(dcName, infra),
+		Description:        formatDescription(result),
 		PublicScope:        "Private",
 	}
 
@@ -150,15 +199,161 @@ func (c *ESMClient) SendRequest(request ESMRequest) error {
 	httpRequest.Header.Add("Content-Type", "application/json")
 	httpRequest.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
 
-	res, err := http.DefaultClient.Do(httpRequest)
+	res, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 && res.StatusCode != 201 {
+		return fmt.Errorf("smax request returned bad status code %d: %s", res.StatusCode, string(resBody))
+	}
+
+	var bulk esmBulkResponse
+	if err := json.Unmarshal(resBody, &bulk); err == nil && bulk.Meta.CompletionStatus == "FAILED" {
+		return fmt.Errorf("smax %s request failed: %s", request.Operation, string(resBody))
+	}
+
+	return nil
+}
+
+// FindOpenRequest looks up the open request created for a DC and infra. It
+// returns nil if there is no open request.
+func (c *ESMClient) FindOpenRequest(dcName, infra string) (*ESMExistingRequest, error) {
+	filter := fmt.Sprintf("DisplayLabel = '%s'", ESMDisplayLabel(dcName, infra))
+	for _, status := range esmClosedStatuses {
+		filter += fmt.Sprintf(" and Status != '%s'", status)
+	}
+
+	query := url.Values{}
+	query.Set("layout", "Id,DisplayLabel,Description,Status")
+	query.Set("filter", filter)
+	query.Set("order", "Id desc")
+
+	httpRequest, err := http.NewRequest("GET", fmt.Sprintf("%s/rest/%d/ems/Request?%s", c.baseURL, c.tenantID, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest.Header.Add("Accept", "application/json")
+	httpRequest.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
+
+	res, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("smax query returned bad status code %d: %s", res.StatusCode, string(resBody))
+	}
+
+	var response esmQueryResponse
+	if err := json.Unmarshal(resBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse smax query response: %w", err)
+	}
+
+	if len(response.Entities) == 0 {
+		return nil, nil
+	}
+
+	// Use the most recent request if several are open
+	latest := response.Entities[0].Properties
+	return &ESMExistingRequest{
+		ID:           latest.Id.String(),
+		DisplayLabel: latest.DisplayLabel,
+		Description:  latest.Description,
+		Status:       latest.Status,
+	}, nil
+}
+
+// CreateUpdateRequest creates a request that replaces the description of an
+// existing request with the findings of a new result
+func (c *ESMClient) CreateUpdateRequest(requestID string, result *checker.Result) ESMRequest {
+	return ESMRequest{
+		Entities: []ESMEntity{
+			{
+				EntityType: "Request",
+				Properties: ESMProperties{
+					Id:          requestID,
+					Description: formatDescription(result),
+				},
+			},
+		},
+		Operation: "UPDATE",
+	}
+}
+
+// CreateCloseRequest creates a request that resolves an existing request
+// because the DC no longer has any findings. The clean report is added as
+// the solution.
+func (c *ESMClient) CreateCloseRequest(requestID string, result *checker.Result) ESMRequest {
+	return ESMRequest{
+		Entities: []ESMEntity{
+			{
+				EntityType: "Request",
+				Properties: ESMProperties{
+					Id:             requestID,
+					Status:         "RequestStatusComplete",
+					CompletionCode: "CompletionCodeFulfilled",
+					Solution:       formatDescription(result),
+				},
+			},
+		},
+		Operation: "UPDATE",
+	}
+}
+
+// AddComment adds a comment to an existing request. Line breaks in the
+// comment are converted to HTML.
+func (c *ESMClient) AddComment(requestID, comment string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"IsSystem":          false,
+		"Body":              strings.ReplaceAll(comment, "\n", "<br>"),
+		"PrivacyType":       "INTERNAL",
+		"FunctionalPurpose": "FollowUp",
+	})
+	if err != nil {
+		return err
+	}
+
+	httpRequest, err := http.NewRequest("POST", fmt.Sprintf("%s/rest/%d/collaboration/comments/Request/%s", c.baseURL, c.tenantID, requestID), bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
+
+	res, err := c.httpClient.Do(httpRequest)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 && res.StatusCode != 201 {
-		return fmt.Errorf("smax request returned bad status code: %s", res.Body)
+		resBody, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("smax comment returned bad status code %d: %s", res.StatusCode, string(resBody))
 	}
 
 	return nil
 }
+
+// formatDescription formats the output of a result for an ESM description.
+// The findings fingerprint is appended so later runs can tell whether the
+// findings have changed.
+func formatDescription(result *checker.Result) string {
+	// Format preview with HTML line breaks for ESM
+	formattedOutput := strings.ReplaceAll(result.Output, "\n", "<br>")
+	return fmt.Sprintf("%s<br>Fingerprint: %s", formattedOutput, result.Fingerprint())
+}
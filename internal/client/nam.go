@@ -1,32 +1,31 @@
 package client
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
 	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
 )
 
+// namPageSize is the number of objects requested per NAM page
+const namPageSize = 500
+
 // NAMClient handles API calls to NAM
 type NAMClient struct {
+	pager
 	httpClient *http.Client
 	baseURL    string
 	apiToken   string
 }
 
 // NewNAMClient creates a new NAM API client
-func NewNAMClient(baseURL, apiToken string) *NAMClient {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-	}
-	httpClient := &http.Client{
-		Transport: tr,
-		Timeout:   30 * time.Second,
-	}
+func NewNAMClient(baseURL, apiToken string, retry config.RetryConfig) *NAMClient {
+	httpClient := newHTTPClient("nam", 30*time.Second, retry)
 
 	return &NAMClient{
 		httpClient: httpClient,
@@ -35,11 +34,92 @@ func NewNAMClient(baseURL, apiToken string) *NAMClient {
 	}
 }
 
-// FetchVxLANs fetches VxLANs from NAM
-func (c *NAMClient) FetchVxLANs() ([]models.NAMVxLAN, error) {
-	url := fmt.Sprintf("%s/api/ipam/vxlans/?expand=1", c.baseURL)
+// FetchVxLANs fetches all VxLANs from NAM. If container is not empty only
+// VxLANs belonging to that container are requested.
+func (c *NAMClient) FetchVxLANs(container string) ([]models.NAMVxLAN, error) {
+	return fetchAllNAM[models.NAMVxLAN](c, "vxlans", "VxLANs", container)
+}
+
+// FetchSubnets fetches all subnets attached to VxLANs from NAM. If
+// container is not empty only subnets in that container are requested.
+func (c *NAMClient) FetchSubnets(container string) ([]models.NAMSubnet, error) {
+	return fetchAllNAM[models.NAMSubnet](c, "subnets", "subnets", container)
+}
+
+// fetchAllNAM fetches every page of a NAM list endpoint and checks that the
+// number of objects matches the count reported by NAM. An object returned
+// twice means NAM ignored the offset and is reported as an error.
+func fetchAllNAM[T any](c *NAMClient, endpoint, what, container string) ([]T, error) {
+	var all []T
+	seen := make(map[int]bool)
+	name := fmt.Sprintf("nam/%s-%s", endpoint, container)
+
+	for n, offset := 1, 0; ; n++ {
+		page, err := c.fetchPage(name, n, endpoint, what, container, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		var results []T
+		if err := json.Unmarshal(page.Results, &results); err != nil {
+			return nil, fmt.Errorf("failed to parse NAM %s response: %w", what, err)
+		}
+		var ids []struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(page.Results, &ids); err != nil {
+			return nil, fmt.Errorf("failed to parse NAM %s response: %w", what, err)
+		}
+		for _, id := range ids {
+			if seen[id.ID] {
+				return nil, fmt.Errorf("NAM returned %s with ID %d twice, the offset %d may have been ignored", what, id.ID, offset)
+			}
+			seen[id.ID] = true
+		}
+
+		all = append(all, results...)
+		offset += len(results)
+
+		if len(results) == 0 || offset >= page.Count {
+			if len(all) != page.Count {
+				return nil, fmt.Errorf("NAM returned %d %s but reported count %d", len(all), what, page.Count)
+			}
+			break
+		}
+	}
+
+	return all, nil
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// fetchPage fetches page n, starting at offset, of a NAM list endpoint
+func (c *NAMClient) fetchPage(name string, n int, endpoint, what, container string, offset int) (*models.NAMResponse, error) {
+	body, err := c.page(name, n, func() ([]byte, error) {
+		return c.get(endpoint, what, container, offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var page models.NAMResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse NAM %s response: %w", what, err)
+	}
+
+	return &page, nil
+}
+
+// get returns the body of a NAM list page
+func (c *NAMClient) get(endpoint, what, container string, offset int) ([]byte, error) {
+	query := url.Values{}
+	query.Set("expand", "1")
+	query.Set("limit", fmt.Sprint(namPageSize))
+	query.Set("offset", fmt.Sprint(offset))
+	if container != "" {
+		query.Set("container", container)
+	}
+	pageURL := fmt.Sprintf("%s/api/ipam/%s/?%s", c.baseURL, endpoint, query.Encode())
+
+	req, err := http.NewRequest("GET", pageURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -49,7 +129,7 @@ func (c *NAMClient) FetchVxLANs() ([]models.NAMVxLAN, error) {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch VxLANs from NAM: %w", err)
+		return nil, fmt.Errorf("failed to fetch %s from NAM: %w", what, err)
 	}
 	defer resp.Body.Close()
 
@@ -63,13 +143,5 @@ func (c *NAMClient) FetchVxLANs() ([]models.NAMVxLAN, error) {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var response struct {
-		Results []models.NAMVxLAN `json:"results"`
-	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse NAM VxLAN response: %w", err)
-	}
-
-	return response.Results, nil
+	return body, nil
 }
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+// namServer serves count VxLANs in pages of pageSize. If ignoreOffset is
+// set every request gets the first page. Pages after the first are empty
+// if truncate is set.
+func namServer(t *testing.T, count, pageSize int, ignoreOffset, truncate bool) *NAMClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if ignoreOffset {
+			offset = 0
+		}
+
+		var results []string
+		for id := offset + 1; id <= min(offset+pageSize, count); id++ {
+			if truncate && offset > 0 {
+				break
+			}
+			results = append(results, fmt.Sprintf(`{"id": %d, "name": "vxlan-%d"}`, id, id))
+		}
+		fmt.Fprintf(w, `{"count": %d, "results": [%s]}`, count, strings.Join(results, ","))
+	}))
+	t.Cleanup(server.Close)
+	return NewNAMClient(server.URL, "token", config.RetryConfig{MaxAttempts: 1})
+}
+
+func TestFetchAllNAM(t *testing.T) {
+	tests := []struct {
+		name         string
+		count        int
+		ignoreOffset bool
+		truncate     bool
+		wantErr      string
+	}{
+		{"every page", 5, false, false, ""},
+		// The repeated first page adds up to the count
+		{"offset ignored", 4, true, false, "twice"},
+		{"count mismatch", 5, false, true, "reported count 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := namServer(t, tt.count, 2, tt.ignoreOffset, tt.truncate)
+
+			vxlans, err := c.FetchVxLANs("dc1")
+			if tt.wantErr == "" {
+				if err != nil || len(vxlans) != tt.count {
+					t.Errorf("FetchVxLANs = %d VxLANs, %v, want %d", len(vxlans), err, tt.count)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FetchVxLANs error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,32 +1,31 @@
 package client
 
 import (
-	"crypto/tls"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
 	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
 )
 
+// netboxPageSize is the number of objects requested per Netbox page
+const netboxPageSize = 1000
+
 // NetboxClient handles API calls to Netbox
 type NetboxClient struct {
+	pager
 	httpClient *http.Client
 	baseURL    string
 	apiToken   string
 }
 
 // NewNetboxClient creates a new Netbox API client
-func NewNetboxClient(baseURL, apiToken string) *NetboxClient {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-	}
-	httpClient := &http.Client{
-		Transport: tr,
-		Timeout:   30 * time.Second,
-	}
+func NewNetboxClient(baseURL, apiToken string, retry config.RetryConfig) *NetboxClient {
+	httpClient := newHTTPClient("netbox", 30*time.Second, retry)
 
 	return &NetboxClient{
 		httpClient: httpClient,
@@ -35,10 +34,72 @@ func NewNetboxClient(baseURL, apiToken string) *NetboxClient {
 	}
 }
 
-// FetchVLANs fetches VLANs from Netbox for a specific site
+// FetchVLANs fetches all VLANs from Netbox for a specific site
 func (c *NetboxClient) FetchVLANs(siteID int) ([]models.NetboxVLAN, error) {
-	url := fmt.Sprintf("%s/api/ipam/vlans/?site_id=%d&limit=1000", c.baseURL, siteID)
+	url := fmt.Sprintf("%s/api/ipam/vlans/?site_id=%d&limit=%d", c.baseURL, siteID, netboxPageSize)
+	return fetchAll[models.NetboxVLAN](c, fmt.Sprintf("netbox/site-%d-vlans", siteID), url, "VLANs")
+}
+
+// FetchPrefixes fetches all prefixes from Netbox for a specific site
+func (c *NetboxClient) FetchPrefixes(siteID int) ([]models.NetboxPrefix, error) {
+	url := fmt.Sprintf("%s/api/ipam/prefixes/?site_id=%d&limit=%d", c.baseURL, siteID, netboxPageSize)
+	return fetchAll[models.NetboxPrefix](c, fmt.Sprintf("netbox/site-%d-prefixes", siteID), url, "prefixes")
+}
+
+// fetchAll follows the Netbox 'next' links starting at url and collects the
+// results of every page. It fails if the number of collected objects differs
+// from the count Netbox reports, so a partial inventory is never returned.
+// The pages are recorded and replayed under name.
+func fetchAll[T any](c *NetboxClient, name, url, kind string) ([]T, error) {
+	var all []T
+	count := 0
+
+	for n := 1; url != ""; n++ {
+		page, err := c.fetchPage(name, n, url, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		var results []T
+		if err := json.Unmarshal(page.Results, &results); err != nil {
+			return nil, fmt.Errorf("failed to parse Netbox %s response: %w", kind, err)
+		}
+		all = append(all, results...)
+		count = page.Count
+
+		url = ""
+		if page.Next != nil {
+			url = *page.Next
+		}
+	}
+
+	if len(all) != count {
+		return nil, fmt.Errorf("Netbox returned %d %s but reported count %d", len(all), kind, count)
+	}
 
+	return all, nil
+}
+
+// fetchPage fetches page n of a Netbox list endpoint. During a replay the
+// 'next' link is only used to tell whether there are more pages.
+func (c *NetboxClient) fetchPage(name string, n int, url, kind string) (*models.NetboxResponse, error) {
+	body, err := c.page(name, n, func() ([]byte, error) {
+		return c.get(url, kind)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var page models.NetboxResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse Netbox %s response: %w", kind, err)
+	}
+
+	return &page, nil
+}
+
+// get returns the body of a Netbox list page
+func (c *NetboxClient) get(url, kind string) ([]byte, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -49,7 +110,7 @@ func (c *NetboxClient) FetchVLANs(siteID int) ([]models.NetboxVLAN, error) {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch VLANs from Netbox: %w", err)
+		return nil, fmt.Errorf("failed to fetch %s from Netbox: %w", kind, err)
 	}
 	defer resp.Body.Close()
 
@@ -63,52 +124,52 @@ func (c *NetboxClient) FetchVLANs(siteID int) ([]models.NetboxVLAN, error) {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var response struct {
-		Results []models.NetboxVLAN `json:"results"`
-	}
+	return body, nil
+}
 
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse Netbox VLAN response: %w", err)
-	}
+// PatchVLAN updates the given fields of a Netbox VLAN
+func (c *NetboxClient) PatchVLAN(id int, fields map[string]interface{}) error {
+	url := fmt.Sprintf("%s/api/ipam/vlans/%d/", c.baseURL, id)
+	return c.patch(url, fields)
+}
 
-	return response.Results, nil
+// PatchPrefix updates the given fields of a Netbox prefix
+func (c *NetboxClient) PatchPrefix(id int, fields map[string]interface{}) error {
+	url := fmt.Sprintf("%s/api/ipam/prefixes/%d/", c.baseURL, id)
+	return c.patch(url, fields)
 }
 
-// FetchPrefixes fetches prefixes from Netbox for a specific site
-func (c *NetboxClient) FetchPrefixes(siteID int) ([]models.NetboxPrefix, error) {
-	url := fmt.Sprintf("%s/api/ipam/prefixes/?site_id=%d&limit=1000", c.baseURL, siteID)
+// patch sends a PATCH request with the given fields to a Netbox object.
+// Setting fields to fixed values is idempotent, so the request is retried.
+func (c *NetboxClient) patch(url string, fields map[string]interface{}) error {
+	if c.replay {
+		return errReplay
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	body, err := json.Marshal(fields)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to marshal Netbox patch: %w", err)
+	}
+
+	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", c.apiToken))
+	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(markRetryable(req))
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch prefixes from Netbox: %w", err)
+		return fmt.Errorf("failed to patch Netbox object: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Netbox API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	var response struct {
-		Results []models.NetboxPrefix `json:"results"`
-	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse Netbox prefix response: %w", err)
+		return fmt.Errorf("Netbox API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return response.Results, nil
+	return nil
 }
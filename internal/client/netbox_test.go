@@ -0,0 +1,63 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+// netboxServer serves pages of two VLANs each, with a 'next' link on every
+// page but the last, and reports count as the total
+func netboxServer(t *testing.T, count, pages int) *NetboxClient {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			n = 1
+		}
+
+		next := "null"
+		if n < pages {
+			next = fmt.Sprintf(`"%s/api/ipam/vlans/?site_id=1&page=%d"`, server.URL, n+1)
+		}
+		results := fmt.Sprintf(`{"id": %d, "vid": %d}, {"id": %d, "vid": %d}`, 2*n-1, 2*n-1, 2*n, 2*n)
+		fmt.Fprintf(w, `{"count": %d, "next": %s, "results": [%s]}`, count, next, results)
+	}))
+	t.Cleanup(server.Close)
+	return NewNetboxClient(server.URL, "token", config.RetryConfig{MaxAttempts: 1})
+}
+
+func TestFetchAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int
+		pages   int
+		wantErr string
+	}{
+		{"every page", 4, 2, ""},
+		{"next ends early", 4, 1, "returned 2 VLANs but reported count 4"},
+		{"count disagrees", 3, 2, "returned 4 VLANs but reported count 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := netboxServer(t, tt.count, tt.pages)
+
+			vlans, err := c.FetchVLANs(1)
+			if tt.wantErr == "" {
+				if err != nil || len(vlans) != tt.count {
+					t.Errorf("FetchVLANs = %d VLANs, %v, want %d", len(vlans), err, tt.count)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FetchVLANs error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
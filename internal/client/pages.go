@@ -0,0 +1,54 @@
+package client
+
+import (
+	"errors"
+	"log"
+)
+
+// PageStore saves and loads the raw response bodies of the list pages the
+// API clients fetch, to record a run and replay it later. Pages are named
+// by the client and numbered from 1.
+type PageStore interface {
+	SavePage(name string, page int, body []byte) error
+	LoadPage(name string, page int) ([]byte, error)
+}
+
+// errReplay is returned for updates during a replay
+var errReplay = errors.New("cannot update the API during a replay")
+
+// pager fetches the pages of list endpoints, recording them to or
+// replaying them from a PageStore if one is set
+type pager struct {
+	store  PageStore
+	replay bool
+}
+
+// RecordTo saves the raw body of every page the client fetches to store
+func (p *pager) RecordTo(store PageStore) {
+	p.store, p.replay = store, false
+}
+
+// ReplayFrom reads every page from store instead of the API
+func (p *pager) ReplayFrom(store PageStore) {
+	p.store, p.replay = store, true
+}
+
+// page returns page n of the list called name, using get to fetch it from
+// the API. A page that cannot be recorded is logged and still returned, a
+// full disk must not stop the checks.
+func (p *pager) page(name string, n int, get func() ([]byte, error)) ([]byte, error) {
+	if p.replay {
+		return p.store.LoadPage(name, n)
+	}
+
+	body, err := get()
+	if err != nil {
+		return nil, err
+	}
+	if p.store != nil {
+		if err := p.store.SavePage(name, n, body); err != nil {
+			log.Printf("✗ Failed to record page %d of %s: %v", n, name, err)
+		}
+	}
+	return body, nil
+}
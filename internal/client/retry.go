@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/metrics"
+)
+
+// Default retry settings used when a backend does not configure its own
+const (
+	defaultMaxAttempts    = 4
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// retryableKey is the context key marking a request as safe to retry
+type retryableKey struct{}
+
+// markRetryable marks a request as safe to retry even though its method is
+// not idempotent, e.g. a POST that only exchanges credentials for a token
+func markRetryable(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), retryableKey{}, true))
+}
+
+// RetryTransport is an http.RoundTripper that retries failed requests with
+// capped exponential backoff and jitter.
+//
+// Idempotent requests and requests marked with markRetryable are retried on
+// network errors and 5xx responses. Every request is retried on 429, since
+// the server rejected it without processing it. Retry-After is honoured up
+// to MaxBackoff. The latency and outcome of every attempt is recorded in
+// the metrics registry under the name of the backend.
+type RetryTransport struct {
+	Base           http.RoundTripper
+	Backend        string
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout limits each attempt, including reading the response body.
+	// Zero means no limit.
+	Timeout time.Duration
+}
+
+// NewRetryTransport creates a RetryTransport for a backend around base using
+// the given retry configuration, falling back to defaults for unset values
+func NewRetryTransport(base http.RoundTripper, backend string, cfg config.RetryConfig) *RetryTransport {
+	t := &RetryTransport{
+		Base:           base,
+		Backend:        backend,
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: time.Duration(cfg.InitialBackoffMS) * time.Millisecond,
+		MaxBackoff:     time.Duration(cfg.MaxBackoffMS) * time.Millisecond,
+	}
+	if t.MaxAttempts <= 0 {
+		t.MaxAttempts = defaultMaxAttempts
+	}
+	if t.InitialBackoff <= 0 {
+		t.InitialBackoff = defaultInitialBackoff
+	}
+	if t.MaxBackoff <= 0 {
+		t.MaxBackoff = defaultMaxBackoff
+	}
+	if t.MaxBackoff < t.InitialBackoff {
+		t.MaxBackoff = t.InitialBackoff
+	}
+	return t
+}
+
+// newHTTPClient creates an HTTP client with a retrying transport. The
+// timeout applies to each attempt, a hung attempt is abandoned and retried
+// like any other network error.
+func newHTTPClient(backend string, timeout time.Duration, cfg config.RetryConfig) *http.Client {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
+	}
+	rt := NewRetryTransport(tr, backend, cfg)
+	rt.Timeout = timeout
+
+	return &http.Client{
+		Transport: rt,
+	}
+}
+
+// cancelOnClose releases the context of an attempt when the response body
+// is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := isIdempotent(req.Method) || req.Context().Value(retryableKey{}) != nil
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := req.Context(), context.CancelFunc(func() {})
+		if t.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(req.Context(), t.Timeout)
+		}
+		attemptReq := req.WithContext(ctx)
+		if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+
+		start := time.Now()
+		resp, err := t.Base.RoundTrip(attemptReq)
+		t.observe(time.Since(start), resp, err)
+		if attempt >= t.MaxAttempts || !t.shouldRetry(req, retryable, resp, err) {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		wait := t.backoff(attempt)
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = min(retryAfter, t.MaxBackoff)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		log.Printf("⟳ %s %s failed (%s), retrying in %v (attempt %d/%d)",
+			req.Method, req.URL.Redacted(), reason, wait.Round(time.Millisecond), attempt+1, t.MaxAttempts)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// observe records the latency and outcome of a single attempt
+func (t *RetryTransport) observe(duration time.Duration, resp *http.Response, err error) {
+	labels := metrics.Labels{"backend": t.Backend}
+	metrics.Observe("dcn_infra_check_api_request_duration_seconds",
+		"Latency of API requests per backend", labels, duration.Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.AddCounter("dcn_infra_check_api_requests_total",
+		"API requests per backend and status code", metrics.Labels{"backend": t.Backend, "code": code}, 1)
+
+	if err != nil || resp.StatusCode >= 400 {
+		metrics.AddCounter("dcn_infra_check_api_errors_total",
+			"API requests per backend that failed or returned an error status", labels, 1)
+	}
+}
+
+// shouldRetry reports whether a request that ended with resp or err should
+// be attempted again
+func (t *RetryTransport) shouldRetry(req *http.Request, retryable bool, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false // the body cannot be replayed
+	}
+	if err != nil {
+		return retryable
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return retryable
+	}
+	return false
+}
+
+// backoff returns the wait before the given attempt is retried: an
+// exponentially growing delay capped at MaxBackoff, of which the upper half
+// is randomised to spread out concurrent clients
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	delay := t.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if d := t.InitialBackoff << shift; d > 0 && d < t.MaxBackoff {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// isIdempotent reports whether requests with the given method can be safely
+// repeated
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
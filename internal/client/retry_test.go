@@ -0,0 +1,247 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// attemptServer answers the nth request with the nth handler, and the last
+// handler for every request after that. It records the body of every
+// request.
+type attemptServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newAttemptServer(t *testing.T, handlers ...http.HandlerFunc) *attemptServer {
+	t.Helper()
+	s := &attemptServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		n := len(s.bodies)
+		s.mu.Unlock()
+		handlers[min(n, len(handlers))-1](w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// attempts returns the number of requests received
+func (s *attemptServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+// requestBodies returns the bodies of the requests received
+func (s *attemptServer) requestBodies() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func status(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}
+}
+
+func newTestTransport(attempts int, initial, max time.Duration) *RetryTransport {
+	return &RetryTransport{
+		Base:           http.DefaultTransport,
+		Backend:        "test",
+		MaxAttempts:    attempts,
+		InitialBackoff: initial,
+		MaxBackoff:     max,
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tr := newTestTransport(10, 100*time.Millisecond, time.Second)
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{40, time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if got := tr.backoff(tt.attempt); got < tt.delay/2 || got > tt.delay {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.delay/2, tt.delay)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(future); !ok || got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, %v, want up to a minute", future, got, ok)
+	}
+}
+
+func TestRetryAfterIsCapped(t *testing.T) {
+	server := newAttemptServer(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+		status(http.StatusOK),
+	)
+	client := &http.Client{Transport: newTestTransport(2, time.Millisecond, 10*time.Millisecond)}
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || server.attempts() != 2 {
+		t.Errorf("status = %d after %d attempts, want 200 after 2", resp.StatusCode, server.attempts())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v, want Retry-After capped at the max backoff", elapsed)
+	}
+}
+
+func TestRetryReplaysBody(t *testing.T) {
+	server := newAttemptServer(t, status(http.StatusServiceUnavailable), status(http.StatusOK))
+	client := &http.Client{Transport: newTestTransport(3, time.Millisecond, time.Millisecond)}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("token request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(markRetryable(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := server.requestBodies(); len(got) != 2 || got[0] != "token request" || got[1] != "token request" {
+		t.Errorf("request bodies = %q, want the same body twice", got)
+	}
+}
+
+func TestRetryPOSTOnlyOn429(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+	}{
+		{"server error", http.StatusInternalServerError, 1},
+		{"bad gateway", http.StatusBadGateway, 1},
+		{"too many requests", http.StatusTooManyRequests, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAttemptServer(t, status(tt.status))
+			client := &http.Client{Transport: newTestTransport(3, time.Millisecond, time.Millisecond)}
+
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := server.attempts(); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryGETOnServerError(t *testing.T) {
+	server := newAttemptServer(t, status(http.StatusBadGateway), status(http.StatusNotFound))
+	client := &http.Client{Transport: newTestTransport(3, time.Millisecond, time.Millisecond)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || server.attempts() != 2 {
+		t.Errorf("status = %d after %d attempts, want 404 after 2", resp.StatusCode, server.attempts())
+	}
+}
+
+func TestTimeoutPerAttempt(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := newAttemptServer(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		},
+	)
+	tr := newTestTransport(2, time.Millisecond, time.Millisecond)
+	tr.Timeout = 100 * time.Millisecond
+	client := &http.Client{Transport: tr}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("hung first attempt was not retried: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The body is read after RoundTrip returned, within the attempt timeout
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "ok" {
+		t.Errorf("body = %q, %v, want ok", body, err)
+	}
+	if server.attempts() != 2 {
+		t.Errorf("attempts = %d, want 2", server.attempts())
+	}
+}
+
+func TestTimeoutLastAttempt(t *testing.T) {
+	server := newAttemptServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	tr := newTestTransport(2, time.Millisecond, time.Millisecond)
+	tr.Timeout = 50 * time.Millisecond
+	client := &http.Client{Transport: tr}
+
+	start := time.Now()
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("hung server did not time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, want about two attempt timeouts", elapsed)
+	}
+}
@@ -2,7 +2,6 @@ package client
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,6 +9,7 @@ import (
 	"time"
 
 	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
 )
 
 // SlackClient handles Slack notifications
@@ -19,14 +19,8 @@ type SlackClient struct {
 }
 
 // NewSlackClient creates a new Slack client
-func NewSlackClient(webhookURL string) *SlackClient {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-	}
-	httpClient := &http.Client{
-		Transport: tr,
-		Timeout:   10 * time.Second,
-	}
+func NewSlackClient(webhookURL string, retry config.RetryConfig) *SlackClient {
+	httpClient := newHTTPClient("slack", 10*time.Second, retry)
 
 	return &SlackClient{
 		webhookURL: webhookURL,
@@ -0,0 +1,28 @@
+package client
+
+import "github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+
+// NetboxSource fetches the VLANs and prefixes of a Netbox site
+type NetboxSource interface {
+	FetchVLANs(siteID int) ([]models.NetboxVLAN, error)
+	FetchPrefixes(siteID int) ([]models.NetboxPrefix, error)
+}
+
+// NetboxWriter updates Netbox objects
+type NetboxWriter interface {
+	PatchVLAN(id int, fields map[string]interface{}) error
+	PatchPrefix(id int, fields map[string]interface{}) error
+}
+
+// NAMSource fetches VxLANs and their subnets from NAM
+type NAMSource interface {
+	FetchVxLANs(container string) ([]models.NAMVxLAN, error)
+	FetchSubnets(container string) ([]models.NAMSubnet, error)
+}
+
+// Compile time checks that the API clients implement the interfaces
+var (
+	_ NetboxSource = (*NetboxClient)(nil)
+	_ NetboxWriter = (*NetboxClient)(nil)
+	_ NAMSource    = (*NAMClient)(nil)
+)
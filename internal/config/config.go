@@ -2,9 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 )
 
 // Config holds all application configuration
@@ -22,7 +27,39 @@ type Config struct {
 	ESMServiceID   string  `json:"esm_service_id"`
 	ESMTeamID      string  `json:"esm_team_id"`
 	SlackWebhook   string  `json:"slack_webhook_url"`
+	HistoryPath    string  `json:"history_path"`
 	Checks         []Check `json:"checks"`
+
+	Suppressions []Suppression `json:"suppressions"`
+
+	// MigrationRules describe how VLAN names change when VLANs are moved.
+	// DefaultMigrationRules is used if the setting is missing.
+	MigrationRules []MigrationRule `json:"migration_rules"`
+
+	NameComparison NameComparison `json:"name_comparison"`
+
+	VRFRules []VRFRule `json:"vrf_rules"`
+
+	VLANPolicies []VLANPolicy `json:"vlan_policies"`
+
+	NetboxRetry RetryConfig `json:"netbox_retry"`
+	NAMRetry    RetryConfig `json:"nam_retry"`
+	ESMRetry    RetryConfig `json:"esm_retry"`
+	SlackRetry  RetryConfig `json:"slack_retry"`
+
+	Metrics MetricsConfig `json:"metrics"`
+	Serve   ServeConfig   `json:"serve"`
+}
+
+// WithoutSecrets returns a copy of the config without the API tokens,
+// passwords and the Slack webhook URL, which holds a token too
+func (c *Config) WithoutSecrets() *Config {
+	clean := *c
+	clean.NetboxAPIToken = ""
+	clean.NAMAPIToken = ""
+	clean.ESMPassword = ""
+	clean.SlackWebhook = ""
+	return &clean
 }
 
 // Check represents a DC check configuration
@@ -32,6 +69,308 @@ type Check struct {
 	DCName       string `json:"dc_name"`
 }
 
+// Suppression hides a known exception from the findings. It applies to the
+// findings of a DC (all DCs if empty) and check type (all checks if empty)
+// that match every one of VLANID, NameRegex and PrefixCIDR that is set.
+type Suppression struct {
+	DCName     string `json:"dc_name"`
+	Check      string `json:"check"`
+	VLANID     *int   `json:"vlan_id"`
+	NameRegex  string `json:"name_regex"`
+	PrefixCIDR string `json:"prefix_cidr"`
+	Reason     string `json:"reason"`
+	// Expires is the last day (YYYY-MM-DD) the suppression applies
+	Expires string `json:"expires"`
+
+	// nameRegex and prefixCIDR are NameRegex and PrefixCIDR parsed when the
+	// config is loaded
+	nameRegex  *regexp.Regexp
+	prefixCIDR netip.Prefix
+}
+
+// SuppressionChecks lists the check types a suppression can be limited
+// to. It must list the same check types as checker.CheckTypes, which cannot
+// be imported here.
+var SuppressionChecks = []string{
+	"moved_vlan",
+	"misconfigured_vlan",
+	"name_mismatch",
+	"wrong_prefix",
+	"stale_vlan",
+	"duplicate",
+	"prefix_integrity",
+	"subnet_mismatch",
+	"prefix_overlap",
+	"wrong_vrf",
+	"vlan_policy",
+}
+
+// suppressionDateLayout is the date format of Suppression.Expires
+const suppressionDateLayout = "2006-01-02"
+
+// Active reports whether the suppression applies at the given time
+func (s *Suppression) Active(now time.Time) bool {
+	if s.Expires == "" {
+		return true
+	}
+	expires, err := time.ParseInLocation(suppressionDateLayout, s.Expires, now.Location())
+	if err != nil {
+		return false
+	}
+	return now.Before(expires.AddDate(0, 0, 1))
+}
+
+// validate checks that the suppression is complete and well-formed
+func (s *Suppression) validate() error {
+	if strings.TrimSpace(s.Reason) == "" {
+		return errors.New("reason is required")
+	}
+	if s.VLANID == nil && s.NameRegex == "" && s.PrefixCIDR == "" {
+		return errors.New("one of vlan_id, name_regex or prefix_cidr is required")
+	}
+	if s.Check != "" && !slices.Contains(SuppressionChecks, s.Check) {
+		return fmt.Errorf("unknown check %q, expected one of %s", s.Check, strings.Join(SuppressionChecks, ", "))
+	}
+	if s.NameRegex != "" {
+		pattern, err := regexp.Compile(s.NameRegex)
+		if err != nil {
+			return fmt.Errorf("invalid name_regex: %w", err)
+		}
+		s.nameRegex = pattern
+	}
+	if s.PrefixCIDR != "" {
+		cidr, err := netip.ParsePrefix(s.PrefixCIDR)
+		if err != nil {
+			return fmt.Errorf("invalid prefix_cidr: %w", err)
+		}
+		s.prefixCIDR = cidr.Masked()
+	}
+	if s.Expires != "" {
+		if _, err := time.Parse(suppressionDateLayout, s.Expires); err != nil {
+			return fmt.Errorf("invalid expires date, expected YYYY-MM-DD: %w", err)
+		}
+	}
+	return nil
+}
+
+// MatchesName reports whether NameRegex matches one of the names. It is
+// true for every name if NameRegex is not set.
+func (s *Suppression) MatchesName(names []string) bool {
+	if s.NameRegex == "" {
+		return true
+	}
+
+	pattern := s.nameRegex
+	if pattern == nil {
+		// Not loaded through LoadConfig
+		var err error
+		if pattern, err = regexp.Compile(s.NameRegex); err != nil {
+			return false
+		}
+	}
+	for _, name := range names {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsPrefix reports whether the prefix lies within PrefixCIDR. It is
+// true for every prefix if PrefixCIDR is not set.
+func (s *Suppression) ContainsPrefix(prefix string) bool {
+	if s.PrefixCIDR == "" {
+		return true
+	}
+
+	cidr := s.prefixCIDR
+	if !cidr.IsValid() {
+		// Not loaded through LoadConfig
+		var err error
+		if cidr, err = netip.ParsePrefix(s.PrefixCIDR); err != nil {
+			return false
+		}
+	}
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return false
+	}
+	return p.Bits() >= cidr.Bits() && cidr.Contains(p.Addr())
+}
+
+// MigrationRule describes a rename of VLANs in Netbox during a migration.
+// The name a Netbox VLAN matching Source is expected to still have in NAM is
+// found by replacing Source with Target. Source and Target are plain
+// substrings, or a regular expression and its replacement with capture
+// groups ($1, ${name}) if Regex is set. The rule applies to the checks of
+// Infra, or all checks if empty.
+type MigrationRule struct {
+	Name   string `json:"name"`
+	Infra  string `json:"infra"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Regex  bool   `json:"regex"`
+}
+
+// DefaultMigrationRules are the migration rules used if none are configured
+var DefaultMigrationRules = []MigrationRule{
+	{Name: "nam-03", Source: "nam-03", Target: "nam-01"},
+}
+
+// String returns the name of the rule, or its source and target if unnamed
+func (m MigrationRule) String() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return fmt.Sprintf("%s -> %s", m.Source, m.Target)
+}
+
+// validate checks that the migration rule is complete and well-formed
+func (m *MigrationRule) validate() error {
+	if m.Source == "" {
+		return errors.New("source is required")
+	}
+	if m.Regex {
+		if _, err := regexp.Compile(m.Source); err != nil {
+			return fmt.Errorf("invalid source regex: %w", err)
+		}
+	}
+	return nil
+}
+
+// NameComparison controls how VLAN names in Netbox and NAM are compared.
+// Names are lowercased and trimmed first, then passed through the
+// normalizers in the order they are listed, each working on the output of
+// the previous one. Values and patterns therefore match regardless of case.
+type NameComparison struct {
+	Normalizers []NameNormalizer `json:"normalizers"`
+	// Similarity adds a similarity score to each name mismatch
+	Similarity bool `json:"similarity"`
+}
+
+// Kinds of name normalizers
+const (
+	// NormalizerFoldSeparators replaces every run of the characters in
+	// Value (default "-_ .") with a single "-"
+	NormalizerFoldSeparators = "fold_separators"
+	// NormalizerStripPrefix removes Value from the start of the name
+	NormalizerStripPrefix = "strip_prefix"
+	// NormalizerStripSuffix removes Value from the end of the name
+	NormalizerStripSuffix = "strip_suffix"
+	// NormalizerRegexReplace replaces every match of Pattern, matched
+	// case-insensitively, with Replacement, which may use capture groups
+	// ($1, ${name})
+	NormalizerRegexReplace = "regex_replace"
+)
+
+// NameNormalizer is one step of name normalisation
+type NameNormalizer struct {
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// validate checks that the normalizer is complete and well-formed
+func (n *NameNormalizer) validate() error {
+	switch n.Type {
+	case NormalizerFoldSeparators:
+		return nil
+	case NormalizerStripPrefix, NormalizerStripSuffix:
+		if n.Value == "" {
+			return errors.New("value is required")
+		}
+		return nil
+	case NormalizerRegexReplace:
+		if _, err := regexp.Compile(n.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown type %q", n.Type)
+	}
+}
+
+// VRFGlobal is the VRF name of rules expecting the global table
+const VRFGlobal = "global"
+
+// VRFRule sets the Netbox VRF expected for the prefixes of VxLANs in a NAM
+// container (all containers if empty) with an ID between MinVxLANID and
+// MaxVxLANID (no limit if 0). VRF is the VRF name, or VRFGlobal for
+// prefixes without a VRF.
+type VRFRule struct {
+	Container  string `json:"container"`
+	MinVxLANID int    `json:"min_vxlan_id"`
+	MaxVxLANID int    `json:"max_vxlan_id"`
+	VRF        string `json:"vrf"`
+}
+
+// Matches reports whether the rule applies to a VxLAN in a container
+func (v *VRFRule) Matches(container string, vxlanID int) bool {
+	if v.Container != "" && v.Container != container {
+		return false
+	}
+	if vxlanID < v.MinVxLANID {
+		return false
+	}
+	return v.MaxVxLANID == 0 || vxlanID <= v.MaxVxLANID
+}
+
+// validate checks that the VRF rule is complete and well-formed
+func (v *VRFRule) validate() error {
+	if v.VRF == "" {
+		return errors.New("vrf is required")
+	}
+	if v.MaxVxLANID != 0 && v.MaxVxLANID < v.MinVxLANID {
+		return errors.New("max_vxlan_id is less than min_vxlan_id")
+	}
+	return nil
+}
+
+// VLANPolicy sets requirements for the Netbox VLANs of the DC's VxLANs in
+// checks of Infra (all checks if empty). Empty lists allow any value; roles
+// and groups are matched by name or slug.
+type VLANPolicy struct {
+	Infra         string   `json:"infra"`
+	Statuses      []string `json:"statuses"`
+	RequireTenant bool     `json:"require_tenant"`
+	Roles         []string `json:"roles"`
+	Groups        []string `json:"groups"`
+}
+
+// validate checks that the VLAN policy has at least one requirement
+func (p *VLANPolicy) validate() error {
+	if len(p.Statuses) == 0 && !p.RequireTenant && len(p.Roles) == 0 && len(p.Groups) == 0 {
+		return errors.New("statuses, require_tenant, roles or groups is required")
+	}
+	return nil
+}
+
+// RetryConfig controls how failed requests to a backend are retried.
+// Zero values fall back to the client defaults.
+type RetryConfig struct {
+	MaxAttempts      int `json:"max_attempts"`
+	InitialBackoffMS int `json:"initial_backoff_ms"`
+	MaxBackoffMS     int `json:"max_backoff_ms"`
+}
+
+// MetricsConfig controls where Prometheus metrics are exported after a run.
+// Metrics are pushed to the Pushgateway and/or written to the textfile if
+// the respective setting is not empty.
+type MetricsConfig struct {
+	PushgatewayURL string `json:"pushgateway_url"`
+	Job            string `json:"job"`
+	TextfilePath   string `json:"textfile_path"`
+}
+
+// ServeConfig controls the long-running serve mode
+type ServeConfig struct {
+	// ListenAddr is the address of the HTTP API, default ":8080"
+	ListenAddr string `json:"listen_addr"`
+	// Schedule is the cron schedule of the checks, default "0 8 * * *"
+	Schedule string `json:"schedule"`
+}
+
 // LoadConfig loads configuration from files
 // Expects:
 // - config/config.json for URLs and check definitions
@@ -39,15 +378,9 @@ type Check struct {
 // - secrets/nam-token for NAM API token
 // - secrets/esm-password for ESM password
 func LoadConfig() (*Config, error) {
-	// Read main config file
-	configData, err := os.ReadFile("config/config.json")
+	cfg, err := LoadConfigFile("config/config.json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	var cfg Config
-	if err := json.Unmarshal(configData, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+		return nil, err
 	}
 
 	// Read Netbox token
@@ -71,6 +404,56 @@ func LoadConfig() (*Config, error) {
 	}
 	cfg.ESMPassword = password
 
+	return cfg, nil
+}
+
+// LoadConfigFile loads and validates the config file at path without any
+// secrets, for runs that do not talk to any API such as replays
+func LoadConfigFile(path string) (*Config, error) {
+	// Read main config file
+	configData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(configData, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+	}
+
+	for i := range cfg.Suppressions {
+		if err := cfg.Suppressions[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid suppression %d: %w", i+1, err)
+		}
+	}
+
+	if cfg.MigrationRules == nil {
+		cfg.MigrationRules = DefaultMigrationRules
+	}
+	for i := range cfg.MigrationRules {
+		if err := cfg.MigrationRules[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid migration rule %d: %w", i+1, err)
+		}
+	}
+
+	for i := range cfg.NameComparison.Normalizers {
+		if err := cfg.NameComparison.Normalizers[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid name normalizer %d: %w", i+1, err)
+		}
+	}
+
+	for i := range cfg.VRFRules {
+		if err := cfg.VRFRules[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid VRF rule %d: %w", i+1, err)
+		}
+	}
+
+	for i := range cfg.VLANPolicies {
+		if err := cfg.VLANPolicies[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid VLAN policy %d: %w", i+1, err)
+		}
+	}
+
 	return &cfg, nil
 }
 
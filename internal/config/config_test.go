@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadNameNormalizers(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer string
+		wantErr    bool
+	}{
+		{"fold separators", `{"type": "fold_separators"}`, false},
+		{"strip prefix", `{"type": "strip_prefix", "value": "vl-"}`, false},
+		{"strip prefix without value", `{"type": "strip_prefix"}`, true},
+		{"strip suffix without value", `{"type": "strip_suffix"}`, true},
+		{"regex replace", `{"type": "regex_replace", "pattern": "^prod-"}`, false},
+		{"invalid pattern", `{"type": "regex_replace", "pattern": "("}`, true},
+		{"unknown type", `{"type": "unknown"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, `{"name_comparison": {"normalizers": [`+tt.normalizer+`]}}`)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSuppressions(t *testing.T) {
+	tests := []struct {
+		name        string
+		suppression string
+		wantErr     bool
+	}{
+		{"every check", `{"vlan_id": 100, "reason": "lab"}`, false},
+		{"known check", `{"check": "name_mismatch", "vlan_id": 100, "reason": "lab"}`, false},
+		{"unknown check", `{"check": "name_mismatches", "vlan_id": 100, "reason": "lab"}`, true},
+		{"without reason", `{"vlan_id": 100}`, true},
+		{"without match", `{"reason": "lab"}`, true},
+		{"invalid expiry", `{"vlan_id": 100, "reason": "lab", "expires": "31.12.2026"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, `{"suppressions": [`+tt.suppression+`]}`)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// loadConfig loads a config file with the content from a temporary
+// directory
+func loadConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfigFile(path)
+}
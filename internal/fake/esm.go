@@ -0,0 +1,205 @@
+package fake
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ESMRequest is a request stored in the fake ESM
+type ESMRequest struct {
+	ID           string
+	DisplayLabel string
+	Description  string
+	Status       string
+	Solution     string
+	Comments     []string
+}
+
+// ESM is a fake ESM (SMAX) API supporting authentication, request queries
+// by display label, bulk CREATE/UPDATE and comments
+type ESM struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]*ESMRequest
+	nextID   int
+}
+
+// esmToken is the token handed out by the fake ESM
+const esmToken = "fake-esm-token"
+
+// esmLabelFilter extracts the display label from a query filter
+var esmLabelFilter = regexp.MustCompile(`DisplayLabel = '([^']*)'`)
+
+// esmClosedStatuses are the statuses the fake ESM treats as closed
+var esmClosedStatuses = map[string]bool{
+	"RequestStatusComplete":  true,
+	"RequestStatusCancelled": true,
+	"RequestStatusRejected":  true,
+}
+
+// NewESM starts a fake ESM. Close it when done.
+func NewESM() *ESM {
+	e := &ESM{
+		requests: make(map[string]*ESMRequest),
+		nextID:   1000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/authentication-endpoint/authenticate/token", e.handleAuth)
+	mux.HandleFunc("GET /rest/{tenant}/ems/Request", e.authorized(e.handleQuery))
+	mux.HandleFunc("POST /rest/{tenant}/ems/bulk", e.authorized(e.handleBulk))
+	mux.HandleFunc("POST /rest/{tenant}/collaboration/comments/Request/{id}", e.authorized(e.handleComment))
+	e.Server = httptest.NewServer(mux)
+
+	return e
+}
+
+// Requests returns a copy of every stored request ordered by ID
+func (e *ESM) Requests() []ESMRequest {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var requests []ESMRequest
+	for _, r := range e.requests {
+		copied := *r
+		copied.Comments = append([]string(nil), r.Comments...)
+		requests = append(requests, copied)
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+	return requests
+}
+
+// handleAuth hands out a token for any credentials
+func (e *ESM) handleAuth(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(esmToken))
+}
+
+// authorized rejects requests without the fake token
+func (e *ESM) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+esmToken {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleQuery returns the open requests with the display label in the
+// filter, newest first
+func (e *ESM) handleQuery(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	label := ""
+	if match := esmLabelFilter.FindStringSubmatch(r.URL.Query().Get("filter")); match != nil {
+		label = match[1]
+	}
+
+	var matches []*ESMRequest
+	for _, req := range e.requests {
+		if req.DisplayLabel == label && !esmClosedStatuses[req.Status] {
+			matches = append(matches, req)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID > matches[j].ID })
+
+	entities := []map[string]interface{}{}
+	for _, req := range matches {
+		entities = append(entities, map[string]interface{}{
+			"entity_type": "Request",
+			"properties": map[string]interface{}{
+				"Id":           json.Number(req.ID),
+				"DisplayLabel": req.DisplayLabel,
+				"Description":  req.Description,
+				"Status":       req.Status,
+			},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entities": entities})
+}
+
+// handleBulk creates or updates requests
+func (e *ESM) handleBulk(w http.ResponseWriter, r *http.Request) {
+	var bulk struct {
+		Operation string `json:"operation"`
+		Entities  []struct {
+			Properties map[string]string `json:"properties"`
+		} `json:"entities"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &bulk); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, entity := range bulk.Entities {
+		props := entity.Properties
+		switch bulk.Operation {
+		case "CREATE":
+			e.nextID++
+			id := strconv.Itoa(e.nextID)
+			e.requests[id] = &ESMRequest{
+				ID:           id,
+				DisplayLabel: props["DisplayLabel"],
+				Description:  props["Description"],
+				Status:       "RequestStatusReady",
+			}
+		case "UPDATE":
+			req, ok := e.requests[props["Id"]]
+			if !ok {
+				writeJSON(w, http.StatusOK, map[string]interface{}{"meta": map[string]string{"completion_status": "FAILED"}})
+				return
+			}
+			if v, ok := props["Description"]; ok {
+				req.Description = v
+			}
+			if v, ok := props["Status"]; ok {
+				req.Status = v
+			}
+			if v, ok := props["Solution"]; ok {
+				req.Solution = v
+			}
+		default:
+			http.Error(w, "unknown operation", http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"meta": map[string]string{"completion_status": "OK"}})
+}
+
+// handleComment adds a comment to a request
+func (e *ESM) handleComment(w http.ResponseWriter, r *http.Request) {
+	var comment struct {
+		Body string `json:"Body"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &comment); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	req, ok := e.requests[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	req.Comments = append(req.Comments, comment.Body)
+
+	writeJSON(w, http.StatusOK, map[string]string{"Id": "c1"})
+}
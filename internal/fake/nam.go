@@ -0,0 +1,155 @@
+package fake
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// NAM is a fake NAM API serving VxLANs and their subnets, filtered by
+// container and paginated with limit and offset
+type NAM struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	vxlans  []models.NAMVxLAN
+	subnets []models.NAMSubnet
+	status  int
+	// subnetStatus fails only the subnet endpoint
+	subnetStatus int
+
+	// PageSize caps the number of objects per page, default 500
+	PageSize int
+}
+
+// NewNAM starts a fake NAM. Close it when done.
+func NewNAM() *NAM {
+	n := &NAM{PageSize: 500}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/ipam/vxlans/", n.handleVxLANs)
+	mux.HandleFunc("GET /api/ipam/subnets/", n.handleSubnets)
+	n.Server = httptest.NewServer(mux)
+
+	return n
+}
+
+// SetVxLANs sets the VxLANs served
+func (n *NAM) SetVxLANs(vxlans ...models.NAMVxLAN) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.vxlans = vxlans
+}
+
+// SetSubnets sets the subnets served
+func (n *NAM) SetSubnets(subnets ...models.NAMSubnet) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subnets = subnets
+}
+
+// Fail makes every request answer with the status code, or serve normally
+// again if status is 0
+func (n *NAM) Fail(status int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.status = status
+}
+
+// FailSubnets makes every subnet request answer with the status code, or
+// serve normally again if status is 0
+func (n *NAM) FailSubnets(status int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subnetStatus = status
+}
+
+// handleVxLANs serves the VxLAN list endpoint
+func (n *NAM) handleVxLANs(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.status != 0 {
+		http.Error(w, "fake failure", n.status)
+		return
+	}
+
+	container := r.URL.Query().Get("container")
+
+	var vxlans []models.NAMVxLAN
+	for _, vxlan := range n.vxlans {
+		if container == "" || vxlan.InContainer(container) {
+			vxlans = append(vxlans, vxlan)
+		}
+	}
+
+	writeOffsetPage(w, r, vxlans, n.PageSize)
+}
+
+// handleSubnets serves the subnet list endpoint. A subnet belongs to the
+// containers of its VxLAN.
+func (n *NAM) handleSubnets(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.status != 0 || n.subnetStatus != 0 {
+		http.Error(w, "fake failure", max(n.status, n.subnetStatus))
+		return
+	}
+
+	container := r.URL.Query().Get("container")
+
+	var subnets []models.NAMSubnet
+	for _, subnet := range n.subnets {
+		if container == "" || n.subnetInContainer(subnet, container) {
+			subnets = append(subnets, subnet)
+		}
+	}
+
+	writeOffsetPage(w, r, subnets, n.PageSize)
+}
+
+// subnetInContainer reports whether the VxLAN of a subnet belongs to the
+// container. The caller must hold mu.
+func (n *NAM) subnetInContainer(subnet models.NAMSubnet, container string) bool {
+	if subnet.VxLAN == nil {
+		return false
+	}
+	for _, vxlan := range n.vxlans {
+		if vxlan.ID == subnet.VxLAN.ID && vxlan.InContainer(container) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOffsetPage writes the page of objects selected by the limit and
+// offset query parameters, with the total count like the NAM API
+func writeOffsetPage[T any](w http.ResponseWriter, r *http.Request, objects []T, pageSize int) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	if limit <= 0 || limit > pageSize {
+		limit = pageSize
+	}
+	if offset > len(objects) {
+		offset = len(objects)
+	}
+	end := offset + limit
+	if end > len(objects) {
+		end = len(objects)
+	}
+
+	results := objects[offset:end]
+	if results == nil {
+		results = []T{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":   len(objects),
+		"results": results,
+	})
+}
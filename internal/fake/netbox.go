@@ -0,0 +1,177 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+// Netbox is a fake Netbox API serving VLANs and prefixes per site. List
+// endpoints are paginated with PageSize objects per page and 'next' links,
+// like Netbox's MAX_PAGE_SIZE.
+type Netbox struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	vlans    map[int][]models.NetboxVLAN
+	prefixes map[int][]models.NetboxPrefix
+	failures map[int]int
+	patches  []Patch
+
+	// PageSize caps the number of objects per page, default 1000
+	PageSize int
+}
+
+// Patch is an update received by the fake Netbox
+type Patch struct {
+	ObjectType string
+	ID         int
+	Fields     map[string]interface{}
+}
+
+// NewNetbox starts a fake Netbox. Close it when done.
+func NewNetbox() *Netbox {
+	n := &Netbox{
+		vlans:    make(map[int][]models.NetboxVLAN),
+		prefixes: make(map[int][]models.NetboxPrefix),
+		failures: make(map[int]int),
+		PageSize: 1000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/ipam/vlans/", n.handleVLANs)
+	mux.HandleFunc("GET /api/ipam/prefixes/", n.handlePrefixes)
+	mux.HandleFunc("PATCH /api/ipam/vlans/{id}/", n.handlePatch("vlan"))
+	mux.HandleFunc("PATCH /api/ipam/prefixes/{id}/", n.handlePatch("prefix"))
+	n.Server = httptest.NewServer(mux)
+
+	return n
+}
+
+// SetVLANs sets the VLANs served for a site
+func (n *Netbox) SetVLANs(siteID int, vlans ...models.NetboxVLAN) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.vlans[siteID] = vlans
+}
+
+// SetPrefixes sets the prefixes served for a site
+func (n *Netbox) SetPrefixes(siteID int, prefixes ...models.NetboxPrefix) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.prefixes[siteID] = prefixes
+}
+
+// FailSite makes every request for a site answer with the status code
+func (n *Netbox) FailSite(siteID, status int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures[siteID] = status
+}
+
+// Patches returns the updates received so far
+func (n *Netbox) Patches() []Patch {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Patch(nil), n.patches...)
+}
+
+// handleVLANs serves the VLAN list endpoint
+func (n *Netbox) handleVLANs(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	siteID, _ := strconv.Atoi(r.URL.Query().Get("site_id"))
+	if status, ok := n.failures[siteID]; ok {
+		http.Error(w, "fake failure", status)
+		return
+	}
+	writePage(w, r, n.vlans[siteID], n.PageSize)
+}
+
+// handlePrefixes serves the prefix list endpoint
+func (n *Netbox) handlePrefixes(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	siteID, _ := strconv.Atoi(r.URL.Query().Get("site_id"))
+	if status, ok := n.failures[siteID]; ok {
+		http.Error(w, "fake failure", status)
+		return
+	}
+	writePage(w, r, n.prefixes[siteID], n.PageSize)
+}
+
+// handlePatch records an update of a VLAN or prefix
+func (n *Netbox) handlePatch(objectType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var fields map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &fields); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		n.mu.Lock()
+		n.patches = append(n.patches, Patch{ObjectType: objectType, ID: id, Fields: fields})
+		n.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": id})
+	}
+}
+
+// writePage writes one page of a Netbox list response, honouring the limit
+// and offset query parameters
+func writePage[T any](w http.ResponseWriter, r *http.Request, objects []T, pageSize int) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	if limit <= 0 || limit > pageSize {
+		limit = pageSize
+	}
+	if offset > len(objects) {
+		offset = len(objects)
+	}
+	end := offset + limit
+	if end > len(objects) {
+		end = len(objects)
+	}
+
+	var next *string
+	if end < len(objects) {
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(end))
+		link := fmt.Sprintf("http://%s%s?%s", r.Host, r.URL.Path, query.Encode())
+		next = &link
+	}
+
+	results := objects[offset:end]
+	if results == nil {
+		results = []T{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":   len(objects),
+		"next":    next,
+		"results": results,
+	})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
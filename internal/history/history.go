@@ -0,0 +1,343 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+)
+
+// Defaults for compacting the store
+const (
+	defaultCompactSize = 4 << 20
+	defaultKeepRecords = 30
+)
+
+// Store is a JSON-lines file holding one record per DC check and run. Once
+// the file grows past its compaction size it is rewritten with only the
+// latest records of each DC check.
+type Store struct {
+	path string
+	// compactSize is the file size in bytes above which Append compacts
+	compactSize int64
+	// keepRecords is the number of records kept per DC check on compaction
+	keepRecords int
+	// latest holds the latest record per DC check, loaded on first use
+	latest map[string]*Record
+}
+
+// Record holds the findings of a single DC check in a run
+type Record struct {
+	RunAt    time.Time `json:"run_at"`
+	DCName   string    `json:"dc_name"`
+	Infra    string    `json:"infra"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is a finding as recorded in the history, with the time it was
+// first seen in an unbroken series of runs
+type Finding struct {
+	Check     string    `json:"check"`
+	VLANID    int       `json:"vlan_id"`
+	Detail    string    `json:"detail"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// Drift classifies the findings of a run against the previous run
+type Drift struct {
+	DCName      string
+	Infra       string
+	PreviousRun *time.Time
+	New         []Finding
+	Persisting  []Finding
+	Resolved    []Finding
+}
+
+// Open returns the store for the file at path. The file is created on the
+// first append.
+func Open(path string) *Store {
+	return &Store{
+		path:        path,
+		compactSize: defaultCompactSize,
+		keepRecords: defaultKeepRecords,
+	}
+}
+
+// Previous returns the latest record for a DC and infra, or nil if the DC
+// has not been recorded before. The file is only read on the first call.
+func (s *Store) Previous(dcName, infra string) (*Record, error) {
+	if s.latest == nil {
+		records, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+		s.latest = make(map[string]*Record)
+		for i := range records {
+			s.latest[records[i].key()] = &records[i]
+		}
+	}
+
+	return s.latest[recordKey(dcName, infra)], nil
+}
+
+// Append adds a record to the end of the store and compacts the store if
+// it has grown past its compaction size
+func (s *Store) Append(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+
+	// Start on a new line if the last write was cut short
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	if s.latest != nil {
+		s.latest[record.key()] = &record
+	}
+
+	// The record is stored, a failed compaction is retried on the next append
+	if info.Size()+int64(len(line)) > s.compactSize {
+		if err := s.compact(); err != nil {
+			log.Printf("✗ History %s not compacted: %v", s.path, err)
+		}
+	}
+	return nil
+}
+
+// read returns every record in the store. Lines that cannot be parsed,
+// e.g. a record cut short by a crash, are skipped.
+func (s *Store) read() ([]Record, error) {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	skipped := 0
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record Record
+			if json.Unmarshal(line, &record) == nil {
+				records = append(records, record)
+			} else {
+				skipped++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+
+	if skipped > 0 {
+		log.Printf("✗ Skipped %d unreadable lines in history %s", skipped, s.path)
+	}
+	return records, nil
+}
+
+// compact rewrites the store with only the latest records of each DC
+// check. The new file replaces the old one atomically.
+func (s *Store) compact() error {
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	// Keep the last records per DC check, in their original order
+	kept := make(map[string]int)
+	keep := make([]bool, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		key := records[i].key()
+		if kept[key] < s.keepRecords {
+			kept[key]++
+			keep[i] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	for i, record := range records {
+		if !keep[i] {
+			continue
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history record: %w", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	return nil
+}
+
+// key identifies the DC check of a record
+func (r Record) key() string {
+	return recordKey(r.DCName, r.Infra)
+}
+
+// recordKey identifies a DC check
+func recordKey(dcName, infra string) string {
+	return dcName + "|" + infra
+}
+
+// Compare builds the record of a result and classifies its findings as new,
+// persisting or resolved since the previous record. A persisting finding
+// keeps the time it was first seen. The previous findings of checks the
+// result skipped are carried over unchanged and left out of the drift.
+func Compare(previous *Record, result *checker.Result, now time.Time) (Record, Drift) {
+	record := Record{
+		RunAt:    now,
+		DCName:   result.DCName,
+		Infra:    result.Infra,
+		Findings: []Finding{},
+	}
+	drift := Drift{
+		DCName: result.DCName,
+		Infra:  result.Infra,
+	}
+
+	seen := make(map[string]Finding)
+	if previous != nil {
+		drift.PreviousRun = &previous.RunAt
+		for _, f := range previous.Findings {
+			seen[f.key()] = f
+		}
+	}
+
+	current := make(map[string]bool)
+	for _, f := range result.Findings() {
+		finding := Finding{
+			Check:     f.Check,
+			VLANID:    f.VLANID,
+			Detail:    f.Detail,
+			FirstSeen: now,
+		}
+		current[finding.key()] = true
+
+		if prev, ok := seen[finding.key()]; ok {
+			finding.FirstSeen = prev.FirstSeen
+			drift.Persisting = append(drift.Persisting, finding)
+		} else {
+			drift.New = append(drift.New, finding)
+		}
+		record.Findings = append(record.Findings, finding)
+	}
+
+	if previous != nil {
+		for _, f := range previous.Findings {
+			switch {
+			case current[f.key()]:
+			case slices.Contains(result.SkippedChecks, f.Check):
+				record.Findings = append(record.Findings, f)
+			default:
+				drift.Resolved = append(drift.Resolved, f)
+			}
+		}
+	}
+
+	// Show the oldest persisting findings first
+	sort.SliceStable(drift.Persisting, func(i, j int) bool {
+		return drift.Persisting[i].FirstSeen.Before(drift.Persisting[j].FirstSeen)
+	})
+
+	return record, drift
+}
+
+// key identifies a finding within a DC check
+func (f Finding) key() string {
+	return checker.Finding{Check: f.Check, VLANID: f.VLANID, Detail: f.Detail}.Key()
+}
+
+// Age returns how long the finding has been open at the given time
+func (f Finding) Age(now time.Time) time.Duration {
+	return now.Sub(f.FirstSeen)
+}
+
+// Format creates formatted output text for the drift
+func (d *Drift) Format(now time.Time) string {
+	var buf bytes.Buffer
+
+	if len(d.New) == 0 && len(d.Persisting) == 0 && len(d.Resolved) == 0 {
+		return ""
+	}
+
+	buf.WriteString(strings.Repeat("=", 75))
+	buf.WriteString("\n")
+	if d.PreviousRun == nil {
+		buf.WriteString(fmt.Sprintf("Endringer for '%s' i '%s' (ingen tidligere kjøring registrert)\n", d.Infra, d.DCName))
+	} else {
+		buf.WriteString(fmt.Sprintf("Endringer for '%s' i '%s' siden forrige kjøring %s\n", d.Infra, d.DCName, d.PreviousRun.Local().Format("2006-01-02 15:04")))
+	}
+	buf.WriteString(strings.Repeat("=", 75))
+	buf.WriteString("\n")
+
+	for _, f := range d.New {
+		buf.WriteString(fmt.Sprintf("+ Ny: [%s] VLAN ID %d -> %s\n", f.Check, f.VLANID, f.Detail))
+	}
+	for _, f := range d.Persisting {
+		buf.WriteString(fmt.Sprintf("= Åpen i %d dager: [%s] VLAN ID %d -> %s\n", int(f.Age(now).Hours()/24), f.Check, f.VLANID, f.Detail))
+	}
+	for _, f := range d.Resolved {
+		buf.WriteString(fmt.Sprintf("- Løst: [%s] VLAN ID %d -> %s\n", f.Check, f.VLANID, f.Detail))
+	}
+	buf.WriteString("\n")
+
+	return buf.String()
+}
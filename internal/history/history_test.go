@@ -0,0 +1,205 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+var (
+	day1 = time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+	day2 = day1.AddDate(0, 0, 1)
+	day3 = day1.AddDate(0, 0, 2)
+)
+
+// resultWithStale returns a result for dc1 with the named VLANs as stale
+// VLANs. The VID of a VLAN depends on the first letter of its name.
+func resultWithStale(names ...string) *checker.Result {
+	result := &checker.Result{DCName: "dc1", Infra: "prod"}
+	for _, name := range names {
+		vid := 100 + int(name[0]-'a')
+		result.StaleVLANs = append(result.StaleVLANs, models.NetboxVLAN{ID: vid, VID: vid, Name: name})
+	}
+	return result
+}
+
+// details returns the details of the findings
+func details(findings []Finding) []string {
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Detail)
+	}
+	return got
+}
+
+func TestCompareFirstRun(t *testing.T) {
+	record, drift := Compare(nil, resultWithStale("a", "b"), day1)
+
+	if drift.PreviousRun != nil {
+		t.Errorf("previous run = %v, want none", drift.PreviousRun)
+	}
+	if got := details(drift.New); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("new = %v, want a and b", got)
+	}
+	if len(drift.Persisting) != 0 || len(drift.Resolved) != 0 {
+		t.Errorf("persisting = %v, resolved = %v, want none", drift.Persisting, drift.Resolved)
+	}
+	for _, f := range record.Findings {
+		if !f.FirstSeen.Equal(day1) {
+			t.Errorf("%s first seen %v, want %v", f.Detail, f.FirstSeen, day1)
+		}
+	}
+}
+
+func TestCompareDrift(t *testing.T) {
+	first, _ := Compare(nil, resultWithStale("a", "b"), day1)
+	second, _ := Compare(&first, resultWithStale("a", "b", "c"), day2)
+	third, drift := Compare(&second, resultWithStale("b", "c"), day3)
+
+	if drift.PreviousRun == nil || !drift.PreviousRun.Equal(day2) {
+		t.Errorf("previous run = %v, want %v", drift.PreviousRun, day2)
+	}
+	if len(drift.New) != 0 {
+		t.Errorf("new = %v, want none", details(drift.New))
+	}
+	if got := details(drift.Resolved); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("resolved = %v, want a", got)
+	}
+
+	// Persisting findings keep when they were first seen, oldest first
+	if got := details(drift.Persisting); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Fatalf("persisting = %v, want b and c", got)
+	}
+	if !drift.Persisting[0].FirstSeen.Equal(day1) || !drift.Persisting[1].FirstSeen.Equal(day2) {
+		t.Errorf("first seen = %v and %v, want %v and %v", drift.Persisting[0].FirstSeen, drift.Persisting[1].FirstSeen, day1, day2)
+	}
+	if got := drift.Persisting[0].Age(day3); got != 48*time.Hour {
+		t.Errorf("age = %v, want 48h", got)
+	}
+	if got := details(third.Findings); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("recorded findings = %v, want b and c", got)
+	}
+}
+
+func TestCompareSkippedCheck(t *testing.T) {
+	vxlan := models.NAMVxLAN{ID: 200, Name: "db-200"}
+	prefix := models.NetboxPrefix{ID: 1, Prefix: "10.0.1.0/24"}
+	withSubnets := resultWithStale("a")
+	withSubnets.SubnetMismatches = []checker.SubnetMismatch{{Kind: checker.SubnetMissingInNAM, VxLAN: vxlan, Prefix: &prefix}}
+	skipped := resultWithStale("a")
+	skipped.SkippedChecks = []string{checker.CheckSubnetMismatches}
+
+	first, _ := Compare(nil, withSubnets, day1)
+	second, drift := Compare(&first, skipped, day2)
+
+	if len(drift.New) != 0 || len(drift.Resolved) != 0 {
+		t.Errorf("new = %v, resolved = %v, want none while the subnet check is skipped", details(drift.New), details(drift.Resolved))
+	}
+	if !reflect.DeepEqual(second.Findings, first.Findings) {
+		t.Errorf("findings = %+v, want the previous findings carried over %+v", second.Findings, first.Findings)
+	}
+
+	// The next complete run still knows when the subnet finding was first seen
+	_, drift = Compare(&second, withSubnets, day3)
+	if len(drift.New) != 0 || len(drift.Persisting) != 2 {
+		t.Fatalf("new = %v, persisting = %v, want both findings persisting", details(drift.New), details(drift.Persisting))
+	}
+	for _, f := range drift.Persisting {
+		if !f.FirstSeen.Equal(day1) {
+			t.Errorf("%s first seen %v, want %v", f.Detail, f.FirstSeen, day1)
+		}
+	}
+}
+
+func TestStorePrevious(t *testing.T) {
+	store := Open(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	if previous, err := store.Previous("dc1", "prod"); err != nil || previous != nil {
+		t.Fatalf("previous without history = %v, %v, want nil", previous, err)
+	}
+
+	first, _ := Compare(nil, resultWithStale("a"), day1)
+	second, _ := Compare(&first, resultWithStale("b"), day2)
+	other := Record{RunAt: day3, DCName: "dc2", Infra: "prod", Findings: []Finding{}}
+	for _, record := range []Record{first, second, other} {
+		if err := store.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new store reads the file
+	previous, err := Open(store.path).Previous("dc1", "prod")
+	if err != nil || previous == nil || !previous.RunAt.Equal(day2) {
+		t.Errorf("previous = %+v, %v, want the record of %v", previous, err, day2)
+	}
+}
+
+func TestStoreSkipsTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := Open(path)
+	first, _ := Compare(nil, resultWithStale("a"), day1)
+	if err := store.Append(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash cut the next record short
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"run_at":"2026-10-15T08:00:00Z","dc_name":"dc1","inf`)
+	file.Close()
+
+	previous, err := Open(path).Previous("dc1", "prod")
+	if err != nil || previous == nil || !previous.RunAt.Equal(day1) {
+		t.Fatalf("previous = %+v, %v, want the record before the truncated line", previous, err)
+	}
+
+	// The next record starts on a line of its own
+	second, _ := Compare(previous, resultWithStale("b"), day3)
+	if err := Open(path).Append(second); err != nil {
+		t.Fatal(err)
+	}
+	previous, err = Open(path).Previous("dc1", "prod")
+	if err != nil || previous == nil || !previous.RunAt.Equal(day3) {
+		t.Errorf("previous after append = %+v, %v, want the record of %v", previous, err, day3)
+	}
+}
+
+func TestStoreCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := Open(path)
+	store.compactSize = 1
+	store.keepRecords = 2
+
+	for i := range 5 {
+		for _, dc := range []string{"dc1", "dc2"} {
+			record := Record{RunAt: day1.AddDate(0, 0, i), DCName: dc, Infra: "prod", Findings: []Finding{}}
+			if err := store.Append(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	records, err := Open(path).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range records {
+		got = append(got, r.DCName+" "+r.RunAt.Format("2006-01-02"))
+	}
+	want := []string{"dc1 2026-10-17", "dc2 2026-10-17", "dc1 2026-10-18", "dc2 2026-10-18"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records after compaction = %v, want %v", got, want)
+	}
+
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Push sends the metrics to a Prometheus Pushgateway. POST is used so that
+// metrics missing from this push, such as the last successful run after a
+// failed run, keep their previously pushed value.
+func (r *Registry) Push(pushgatewayURL, job string) error {
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/metrics/job/%s", strings.TrimRight(pushgatewayURL, "/"), url.PathEscape(job))
+
+	req, err := http.NewRequest("POST", endpoint, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Pushgateway returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// WriteTextfile writes the metrics to a file for the node exporter textfile
+// collector. The file is replaced atomically so it is never read half written.
+func (r *Registry) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create metrics file: %w", err)
+	}
+
+	if err := r.Write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadTextfileValue reads the value of an unlabelled metric from a file
+// previously written with WriteTextfile
+func ReadTextfileValue(path, name string) (float64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == name {
+			value, err := strconv.ParseFloat(fields[1], 64)
+			return value, err == nil
+		}
+	}
+	return 0, false
+}
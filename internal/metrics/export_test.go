@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "check.prom")
+	if err := os.WriteFile(path, []byte("old 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep the old file open, as the node exporter could be reading it
+	old, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+
+	r := NewRegistry()
+	r.SetGauge("last_run", "Last run", nil, 1700000000)
+	if err := r.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# HELP last_run Last run\n# TYPE last_run gauge\nlast_run 1.7e+09\n"; string(data) != want {
+		t.Errorf("textfile = %q, want %q", data, want)
+	}
+
+	// The file is replaced rather than rewritten in place
+	buf := make([]byte, 64)
+	n, _ := old.Read(buf)
+	if string(buf[:n]) != "old 1\n" {
+		t.Errorf("the open file was rewritten in place: %q", buf[:n])
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("textfile mode = %v, want 0644", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files were left behind: %v", entries)
+	}
+}
+
+func TestWriteTextfileMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "check.prom")
+	if err := NewRegistry().WriteTextfile(path); err == nil {
+		t.Error("WriteTextfile succeeded in a missing directory")
+	}
+}
+
+func TestReadTextfileValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "check.prom")
+	content := `# HELP last_success Last success
+# TYPE last_success gauge
+last_success 1.7e+09
+last_success_total 5
+labelled{dc="dc1"} 3
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value float64
+		ok    bool
+	}{
+		{"last_success", 1.7e9, true},
+		{"last_success_total", 5, true},
+		{"labelled", 0, false},
+		{"missing", 0, false},
+	}
+	for _, tt := range tests {
+		value, ok := ReadTextfileValue(path, tt.name)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("ReadTextfileValue(%q) = %v, %v, want %v, %v", tt.name, value, ok, tt.value, tt.ok)
+		}
+	}
+
+	if _, ok := ReadTextfileValue(filepath.Join(t.TempDir(), "missing.prom"), "last_success"); ok {
+		t.Error("ReadTextfileValue found a value in a missing file")
+	}
+}
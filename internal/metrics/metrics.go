@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types in the Prometheus text format
+const (
+	typeGauge     = "gauge"
+	typeCounter   = "counter"
+	typeHistogram = "histogram"
+)
+
+// DefaultBuckets are the histogram buckets used for latencies in seconds
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// labelEscaper escapes label values for the text format, which only
+// allows escaped backslashes, double quotes and line feeds
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// helpEscaper escapes help text for the text format
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// Labels are the labels of a single series
+type Labels map[string]string
+
+// Registry holds metrics and renders them in the Prometheus text format
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// family is a named metric with all of its series
+type family struct {
+	name   string
+	help   string
+	kind   string
+	series map[string]*series
+}
+
+// series is a single labelled value of a metric
+type series struct {
+	labels  string
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// Default is the registry used by the package level functions
+var Default = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// SetGauge sets a gauge on the default registry
+func SetGauge(name, help string, labels Labels, value float64) {
+	Default.SetGauge(name, help, labels, value)
+}
+
+// AddCounter adds to a counter on the default registry
+func AddCounter(name, help string, labels Labels, delta float64) {
+	Default.AddCounter(name, help, labels, delta)
+}
+
+// Observe records a histogram observation on the default registry
+func Observe(name, help string, labels Labels, value float64) {
+	Default.Observe(name, help, labels, value)
+}
+
+// SetGauge sets the value of a gauge
+func (r *Registry) SetGauge(name, help string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, help, typeGauge, labels).value = value
+}
+
+// AddCounter adds delta to a counter
+func (r *Registry) AddCounter(name, help string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, help, typeCounter, labels).value += delta
+}
+
+// Observe records a value in a histogram using DefaultBuckets
+func (r *Registry) Observe(name, help string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(name, help, typeHistogram, labels)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(DefaultBuckets))
+	}
+	for i, bound := range DefaultBuckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Value returns the current value of a gauge or counter and whether it exists
+func (r *Registry) Value(name string, labels Labels) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		return 0, false
+	}
+	s, ok := f.series[formatLabels(labels)]
+	if !ok {
+		return 0, false
+	}
+	return s.value, true
+}
+
+// series returns the series for the labels, creating the metric and the
+// series if needed. The caller must hold the lock.
+func (r *Registry) series(name, help, kind string, labels Labels) *series {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{
+			name:   name,
+			help:   help,
+			kind:   kind,
+			series: make(map[string]*series),
+		}
+		r.families[name] = f
+	}
+
+	key := formatLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: key}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Write renders every metric in the Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		buf.WriteString(fmt.Sprintf("# HELP %s %s\n", f.name, helpEscaper.Replace(f.help)))
+		buf.WriteString(fmt.Sprintf("# TYPE %s %s\n", f.name, f.kind))
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != typeHistogram {
+				buf.WriteString(fmt.Sprintf("%s%s %s\n", f.name, braces(s.labels), formatValue(s.value)))
+				continue
+			}
+
+			for i, bound := range DefaultBuckets {
+				buf.WriteString(fmt.Sprintf("%s_bucket%s %d\n", f.name, braces(joinLabels(s.labels, `le="`+formatValue(bound)+`"`)), s.buckets[i]))
+			}
+			buf.WriteString(fmt.Sprintf("%s_bucket%s %d\n", f.name, braces(joinLabels(s.labels, `le="+Inf"`)), s.count))
+			buf.WriteString(fmt.Sprintf("%s_sum%s %s\n", f.name, braces(s.labels), formatValue(s.sum)))
+			buf.WriteString(fmt.Sprintf("%s_count%s %d\n", f.name, braces(s.labels), s.count))
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// formatLabels renders labels sorted by name without surrounding braces
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(labels[name])))
+	}
+	return strings.Join(parts, ",")
+}
+
+// joinLabels appends a rendered label to rendered labels
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+// braces wraps rendered labels in braces unless there are none
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// formatValue renders a sample value
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	r.SetGauge("b_gauge", "A gauge", Labels{"dc": "dc2", "infra": "prod"}, 2)
+	r.SetGauge("b_gauge", "A gauge", Labels{"infra": "prod", "dc": "dc1"}, 1.5)
+	r.AddCounter("a_total", "A counter", nil, 1)
+	r.AddCounter("a_total", "A counter", nil, 2)
+	r.SetGauge("c_inf", "Infinite", nil, math.Inf(1))
+
+	want := `# HELP a_total A counter
+# TYPE a_total counter
+a_total 3
+# HELP b_gauge A gauge
+# TYPE b_gauge gauge
+b_gauge{dc="dc1",infra="prod"} 1.5
+b_gauge{dc="dc2",infra="prod"} 2
+# HELP c_inf Infinite
+# TYPE c_inf gauge
+c_inf +Inf
+`
+	assertOutput(t, r, want)
+}
+
+func TestWriteHistogram(t *testing.T) {
+	r := NewRegistry()
+	for _, v := range []float64{0.07, 0.3, 45} {
+		r.Observe("latency_seconds", "Latency", Labels{"backend": "netbox"}, v)
+	}
+
+	want := `# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{backend="netbox",le="0.05"} 0
+latency_seconds_bucket{backend="netbox",le="0.1"} 1
+latency_seconds_bucket{backend="netbox",le="0.25"} 1
+latency_seconds_bucket{backend="netbox",le="0.5"} 2
+latency_seconds_bucket{backend="netbox",le="1"} 2
+latency_seconds_bucket{backend="netbox",le="2.5"} 2
+latency_seconds_bucket{backend="netbox",le="5"} 2
+latency_seconds_bucket{backend="netbox",le="10"} 2
+latency_seconds_bucket{backend="netbox",le="30"} 2
+latency_seconds_bucket{backend="netbox",le="+Inf"} 3
+latency_seconds_sum{backend="netbox"} 45.37
+latency_seconds_count{backend="netbox"} 3
+`
+	assertOutput(t, r, want)
+}
+
+func TestWriteEscapes(t *testing.T) {
+	r := NewRegistry()
+	r.SetGauge("escaped", "Help with \\ and\nnewline", Labels{"dc": "a\"b\\c\nd", "site": "Bærum\tøst"}, 1)
+
+	want := `# HELP escaped Help with \\ and\nnewline
+# TYPE escaped gauge
+escaped{dc="a\"b\\c\nd",site="Bærum` + "\t" + `øst"} 1
+`
+	assertOutput(t, r, want)
+}
+
+func TestValue(t *testing.T) {
+	r := NewRegistry()
+	r.SetGauge("up", "Up", Labels{"dc": "dc1"}, 1)
+
+	if v, ok := r.Value("up", Labels{"dc": "dc1"}); !ok || v != 1 {
+		t.Errorf("Value = %v, %v, want 1, true", v, ok)
+	}
+	if _, ok := r.Value("up", Labels{"dc": "dc2"}); ok {
+		t.Error("Value found a series that was never set")
+	}
+	if _, ok := r.Value("down", nil); ok {
+		t.Error("Value found a metric that was never set")
+	}
+}
+
+// assertOutput checks the text format rendered by the registry
+func assertOutput(t *testing.T, r *Registry, want string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
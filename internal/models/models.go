@@ -1,21 +1,72 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // NetboxVLAN represents a VLAN from Netbox
 type NetboxVLAN struct {
 	ID           int                    `json:"id"`
 	VID          int                    `json:"vid"`
 	Name         string                 `json:"name"`
+	Status       *Choice                `json:"status,omitempty"`
+	Tenant       *NestedObject          `json:"tenant,omitempty"`
+	Role         *NestedObject          `json:"role,omitempty"`
+	Group        *NestedObject          `json:"group,omitempty"`
 	CustomFields map[string]interface{} `json:"custom_fields"`
 }
 
+// NestedObject is a nested reference to a Netbox object such as a tenant,
+// role or VLAN group
+type NestedObject struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// Is reports whether the object has the name or slug. A nil object has
+// neither.
+func (o *NestedObject) Is(nameOrSlug string) bool {
+	return o != nil && (o.Name == nameOrSlug || o.Slug == nameOrSlug)
+}
+
+// String returns the name of the object, or "" for a nil object
+func (o *NestedObject) String() string {
+	if o == nil {
+		return ""
+	}
+	return o.Name
+}
+
 // NetboxPrefix represents a prefix from Netbox
 type NetboxPrefix struct {
 	ID           int                    `json:"id"`
 	Prefix       string                 `json:"prefix"`
+	Status       *Choice                `json:"status,omitempty"`
+	VRF          *VRFReference          `json:"vrf,omitempty"`
 	VLAN         *VLANReference         `json:"vlan"`
 	CustomFields map[string]interface{} `json:"custom_fields"`
 }
 
+// VRFReference is a nested VRF reference in a Netbox prefix or NAM VxLAN.
+// Prefixes without a VRF are in the global table.
+type VRFReference struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Choice is a Netbox choice field such as a status
+type Choice struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// PrefixStatusContainer is the status of prefixes that only group other
+// prefixes and are not assigned to a VLAN
+const PrefixStatusContainer = "container"
+
 // VLANReference is a nested VLAN reference in a prefix
 type VLANReference struct {
 	ID   int    `json:"id"`
@@ -25,9 +76,23 @@ type VLANReference struct {
 
 // NAMVxLAN represents a VxLAN from NAM
 type NAMVxLAN struct {
-	ID         int         `json:"id"`
-	Name       string      `json:"name"`
-	Containers []Container `json:"containers"`
+	ID         int           `json:"id"`
+	Name       string        `json:"name"`
+	VRF        *VRFReference `json:"vrf,omitempty"`
+	Containers []Container   `json:"containers"`
+}
+
+// NAMSubnet represents a subnet attached to a VxLAN in NAM
+type NAMSubnet struct {
+	ID     int             `json:"id"`
+	Prefix string          `json:"prefix"`
+	VxLAN  *VxLANReference `json:"vxlan"`
+}
+
+// VxLANReference is a nested VxLAN reference in a NAM subnet
+type VxLANReference struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
 }
 
 // Container represents a container in NAM VxLAN
@@ -36,16 +101,18 @@ type Container struct {
 	Name string `json:"name"`
 }
 
-// NetboxResponse is the generic response structure from Netbox API
+// NetboxResponse is the generic paginated response structure from Netbox API
 type NetboxResponse struct {
-	Count   int         `json:"count"`
-	Results interface{} `json:"results"`
+	Count    int             `json:"count"`
+	Next     *string         `json:"next"`
+	Previous *string         `json:"previous"`
+	Results  json.RawMessage `json:"results"`
 }
 
-// NAMResponse is the generic response structure from NAM API
+// NAMResponse is the generic paginated response structure from NAM API
 type NAMResponse struct {
-	Count   int         `json:"count"`
-	Results interface{} `json:"results"`
+	Count   int             `json:"count"`
+	Results json.RawMessage `json:"results"`
 }
 
 // GetInfra safely extracts the infra custom field from Netbox objects
@@ -58,6 +125,14 @@ func (v *NetboxVLAN) GetInfra() string {
 	return ""
 }
 
+// GetStatus returns the status value of the VLAN, or "" if not set
+func (v *NetboxVLAN) GetStatus() string {
+	if v.Status != nil {
+		return v.Status.Value
+	}
+	return ""
+}
+
 // GetInfra safely extracts the infra custom field from Netbox prefix
 func (p *NetboxPrefix) GetInfra() string {
 	if p.CustomFields != nil {
@@ -68,10 +143,55 @@ func (p *NetboxPrefix) GetInfra() string {
 	return ""
 }
 
-// GetContainerName returns the first container name if it exists
-func (v *NAMVxLAN) GetContainerName() string {
-	if len(v.Containers) > 0 {
-		return v.Containers[0].Name
+// GetStatus returns the status value of the prefix, or "" if not set
+func (p *NetboxPrefix) GetStatus() string {
+	if p.Status != nil {
+		return p.Status.Value
+	}
+	return ""
+}
+
+// GetVRFName returns the name of the prefix's VRF, or "" for the global table
+func (p *NetboxPrefix) GetVRFName() string {
+	if p.VRF != nil {
+		return p.VRF.Name
+	}
+	return ""
+}
+
+// GetVRFName returns the name of the VxLAN's VRF in NAM, or "" if not set
+func (v *NAMVxLAN) GetVRFName() string {
+	if v.VRF != nil {
+		return v.VRF.Name
 	}
 	return ""
 }
+
+// ContainerNames returns the names of every container of the VxLAN
+func (v *NAMVxLAN) ContainerNames() []string {
+	names := make([]string, 0, len(v.Containers))
+	for _, c := range v.Containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// InContainer reports whether the VxLAN belongs to the named container
+func (v *NAMVxLAN) InContainer(name string) bool {
+	for _, c := range v.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the link to the VLAN in the Netbox web UI
+func (v *NetboxVLAN) URL(netboxURL string) string {
+	return fmt.Sprintf("%s/ipam/vlans/%d/", strings.TrimRight(netboxURL, "/"), v.ID)
+}
+
+// URL returns the link to the prefix in the Netbox web UI
+func (p *NetboxPrefix) URL(netboxURL string) string {
+	return fmt.Sprintf("%s/ipam/prefixes/%d/", strings.TrimRight(netboxURL, "/"), p.ID)
+}
@@ -0,0 +1,217 @@
+package remediation
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/client"
+)
+
+// Kinds of remediation actions
+const (
+	KindSetPrefixInfra = "set_prefix_infra"
+	KindRenameVLAN     = "rename_vlan"
+)
+
+// Object types a remediation action can change
+const (
+	ObjectVLAN   = "vlan"
+	ObjectPrefix = "prefix"
+)
+
+// Action is a single mechanical change to a Netbox object
+type Action struct {
+	Kind       string
+	ObjectType string
+	ObjectID   int
+	Object     string
+	Field      string
+	From       string
+	To         string
+}
+
+// Outcome records whether an action was applied and why it failed
+type Outcome struct {
+	DCName  string
+	Action  Action
+	Applied bool
+	Err     error
+}
+
+// Plan is the list of actions that fixes the mechanical findings of a result
+type Plan struct {
+	DCName  string
+	Infra   string
+	Actions []Action
+	Skipped []string
+}
+
+// BuildPlan computes the remediation plan for a result
+func BuildPlan(result *checker.Result) *Plan {
+	plan := &Plan{
+		DCName: result.DCName,
+		Infra:  result.Infra,
+	}
+
+	// Set the infra custom field on prefixes attached to the DC's VxLANs
+	for _, wp := range result.WrongPrefixes {
+		plan.Actions = append(plan.Actions, Action{
+			Kind:       KindSetPrefixInfra,
+			ObjectType: ObjectPrefix,
+			ObjectID:   wp.Prefix.ID,
+			Object:     wp.Prefix.Prefix,
+			Field:      "infra",
+			From:       wp.Prefix.GetInfra(),
+			To:         result.Infra,
+		})
+	}
+
+	// Give prefixes the infra of their VLAN when the VLAN has the check's
+	// infra. Otherwise it is not clear which of them is wrong.
+	for _, issue := range result.PrefixIssues {
+		if issue.Kind != checker.PrefixInfraMismatch {
+			continue
+		}
+		if issue.VLAN.GetInfra() != result.Infra {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("[Prefix %s] vlan %d har infra '%s', endrer ikke infra", issue.Prefix.Prefix, issue.VLAN.VID, issue.VLAN.GetInfra()))
+			continue
+		}
+		plan.Actions = append(plan.Actions, Action{
+			Kind:       KindSetPrefixInfra,
+			ObjectType: ObjectPrefix,
+			ObjectID:   issue.Prefix.ID,
+			Object:     issue.Prefix.Prefix,
+			Field:      "infra",
+			From:       issue.Prefix.GetInfra(),
+			To:         result.Infra,
+		})
+	}
+
+	// Rename Netbox VLANs to the name they have in NAM, unless the VID is
+	// duplicated in Netbox or NAM and the right name is not clear
+	duplicated := duplicatedVIDs(result.Duplicates)
+	for _, nm := range result.NameMismatches {
+		vxlan := nm.VxLAN
+		candidates := nm.NetboxVLANs
+		if len(candidates) != 1 {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("[NAM VLAN ID %d] %d Netbox VLANs har samme VID, endrer ikke navn", vxlan.ID, len(candidates)))
+			continue
+		}
+		if duplicated[vxlan.ID] {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("[NAM VLAN ID %d] VID er duplisert, endrer ikke navn", vxlan.ID))
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, Action{
+			Kind:       KindRenameVLAN,
+			ObjectType: ObjectVLAN,
+			ObjectID:   candidates[0].ID,
+			Object:     fmt.Sprintf("VLAN %d", candidates[0].VID),
+			Field:      "name",
+			From:       candidates[0].Name,
+			To:         vxlan.Name,
+		})
+	}
+
+	return plan
+}
+
+// duplicatedVIDs returns the VIDs of every VLAN and VxLAN in a duplicate
+func duplicatedVIDs(duplicates []checker.Duplicate) map[int]bool {
+	vids := make(map[int]bool)
+	for _, d := range duplicates {
+		for _, vlan := range d.NetboxVLANs {
+			vids[vlan.VID] = true
+		}
+		for _, vxlan := range d.NAMVxLANs {
+			vids[vxlan.ID] = true
+		}
+	}
+	return vids
+}
+
+// DryRun returns the outcomes of the plan without applying any action
+func (p *Plan) DryRun() []Outcome {
+	var outcomes []Outcome
+	for _, action := range p.Actions {
+		outcomes = append(outcomes, Outcome{
+			DCName: p.DCName,
+			Action: action,
+		})
+	}
+	return outcomes
+}
+
+// Apply applies every action of the plan through the Netbox writer. A
+// failing action does not stop the remaining actions.
+func (p *Plan) Apply(netbox client.NetboxWriter) []Outcome {
+	var outcomes []Outcome
+	for _, action := range p.Actions {
+		err := apply(netbox, action)
+		outcomes = append(outcomes, Outcome{
+			DCName:  p.DCName,
+			Action:  action,
+			Applied: err == nil,
+			Err:     err,
+		})
+	}
+	return outcomes
+}
+
+// apply performs a single action
+func apply(netbox client.NetboxWriter, action Action) error {
+	switch action.Kind {
+	case KindSetPrefixInfra:
+		return netbox.PatchPrefix(action.ObjectID, map[string]interface{}{
+			"custom_fields": map[string]interface{}{
+				action.Field: action.To,
+			},
+		})
+	case KindRenameVLAN:
+		return netbox.PatchVLAN(action.ObjectID, map[string]interface{}{
+			action.Field: action.To,
+		})
+	default:
+		return fmt.Errorf("unknown remediation action %q", action.Kind)
+	}
+}
+
+// FormatOutcomes creates formatted output text for the outcomes of a plan
+func FormatOutcomes(plan *Plan, outcomes []Outcome, dryRun bool) string {
+	var buf bytes.Buffer
+
+	if len(outcomes) == 0 && len(plan.Skipped) == 0 {
+		return ""
+	}
+
+	buf.WriteString(strings.Repeat("=", 75))
+	buf.WriteString("\n")
+	if dryRun {
+		buf.WriteString(fmt.Sprintf("Foreslåtte utbedringer i Netbox for '%s' i '%s' (tørrkjøring)\n", plan.Infra, plan.DCName))
+	} else {
+		buf.WriteString(fmt.Sprintf("Utbedringer i Netbox for '%s' i '%s'\n", plan.Infra, plan.DCName))
+	}
+	buf.WriteString(strings.Repeat("=", 75))
+	buf.WriteString("\n")
+
+	for _, o := range outcomes {
+		line := fmt.Sprintf("[%s %d] %s: %s '%s' -> '%s'", o.Action.ObjectType, o.Action.ObjectID, o.Action.Object, o.Action.Field, o.Action.From, o.Action.To)
+		switch {
+		case dryRun:
+			buf.WriteString(fmt.Sprintf("• %s\n", line))
+		case o.Err != nil:
+			buf.WriteString(fmt.Sprintf("✗ %s feilet: %v\n", line, o.Err))
+		default:
+			buf.WriteString(fmt.Sprintf("✓ %s\n", line))
+		}
+	}
+
+	for _, skipped := range plan.Skipped {
+		buf.WriteString(fmt.Sprintf("- %s\n", skipped))
+	}
+	buf.WriteString("\n")
+
+	return buf.String()
+}
@@ -0,0 +1,114 @@
+package remediation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+)
+
+func TestBuildPlanRenames(t *testing.T) {
+	vxlan := models.NAMVxLAN{ID: 100, Name: "app-100"}
+	vlan := models.NetboxVLAN{ID: 1, VID: 100, Name: "app-old"}
+	other := models.NetboxVLAN{ID: 2, VID: 100, Name: "app-older"}
+
+	tests := []struct {
+		name        string
+		result      *checker.Result
+		wantActions int
+		wantSkipped []string
+	}{
+		{
+			name: "one VLAN",
+			result: &checker.Result{
+				NameMismatches: []checker.NameMismatch{{VxLAN: vxlan, NetboxVLANs: []models.NetboxVLAN{vlan}}},
+			},
+			wantActions: 1,
+		},
+		{
+			name: "several VLANs with the VID",
+			result: &checker.Result{
+				NameMismatches: []checker.NameMismatch{{VxLAN: vxlan, NetboxVLANs: []models.NetboxVLAN{vlan, other}}},
+			},
+			wantSkipped: []string{"[NAM VLAN ID 100] 2 Netbox VLANs har samme VID, endrer ikke navn"},
+		},
+		{
+			name: "VxLAN ID duplicated in NAM",
+			result: &checker.Result{
+				NameMismatches: []checker.NameMismatch{{VxLAN: vxlan, NetboxVLANs: []models.NetboxVLAN{vlan}}},
+				Duplicates: []checker.Duplicate{{Kind: checker.DuplicateVxLANID, VID: 100, NAMVxLANs: []models.NAMVxLAN{
+					vxlan, {ID: 100, Name: "app-b"},
+				}}},
+			},
+			wantSkipped: []string{"[NAM VLAN ID 100] VID er duplisert, endrer ikke navn"},
+		},
+		{
+			name: "name duplicated in Netbox",
+			result: &checker.Result{
+				NameMismatches: []checker.NameMismatch{{VxLAN: vxlan, NetboxVLANs: []models.NetboxVLAN{vlan}}},
+				Duplicates: []checker.Duplicate{{Kind: checker.DuplicateName, Name: "app-old", NetboxVLANs: []models.NetboxVLAN{
+					vlan, {ID: 3, VID: 300, Name: "app-old"},
+				}}},
+			},
+			wantSkipped: []string{"[NAM VLAN ID 100] VID er duplisert, endrer ikke navn"},
+		},
+		{
+			name: "another VID duplicated",
+			result: &checker.Result{
+				NameMismatches: []checker.NameMismatch{{VxLAN: vxlan, NetboxVLANs: []models.NetboxVLAN{vlan}}},
+				Duplicates: []checker.Duplicate{{Kind: checker.DuplicateVxLANID, VID: 200, NAMVxLANs: []models.NAMVxLAN{
+					{ID: 200, Name: "db-a"}, {ID: 200, Name: "db-b"},
+				}}},
+			},
+			wantActions: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := BuildPlan(tt.result)
+			if len(plan.Actions) != tt.wantActions {
+				t.Errorf("actions = %+v, want %d", plan.Actions, tt.wantActions)
+			}
+			if !reflect.DeepEqual(plan.Skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %q, want %q", plan.Skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestBuildPlanPrefixInfra(t *testing.T) {
+	vlan := func(vid int, infra string) *models.NetboxVLAN {
+		return &models.NetboxVLAN{ID: vid, VID: vid, CustomFields: map[string]interface{}{"infra": infra}}
+	}
+	prefix := func(id int, cidr, infra string) models.NetboxPrefix {
+		return models.NetboxPrefix{ID: id, Prefix: cidr, CustomFields: map[string]interface{}{"infra": infra}}
+	}
+
+	result := &checker.Result{
+		Infra: "prod",
+		PrefixIssues: []checker.PrefixIssue{
+			{Kind: checker.PrefixInfraMismatch, Prefix: prefix(1, "10.0.1.0/24", "test"), VLAN: vlan(100, "prod")},
+			{Kind: checker.PrefixInfraMismatch, Prefix: prefix(2, "10.0.2.0/24", "prod"), VLAN: vlan(200, "dev")},
+			{Kind: checker.PrefixNoVLAN, Prefix: prefix(3, "10.0.3.0/24", "prod")},
+		},
+	}
+
+	plan := BuildPlan(result)
+	want := []Action{{
+		Kind:       KindSetPrefixInfra,
+		ObjectType: ObjectPrefix,
+		ObjectID:   1,
+		Object:     "10.0.1.0/24",
+		Field:      "infra",
+		From:       "test",
+		To:         "prod",
+	}}
+	if !reflect.DeepEqual(plan.Actions, want) {
+		t.Errorf("actions = %+v, want %+v", plan.Actions, want)
+	}
+	wantSkipped := []string{"[Prefix 10.0.2.0/24] vlan 200 har infra 'dev', endrer ikke infra"}
+	if !reflect.DeepEqual(plan.Skipped, wantSkipped) {
+		t.Errorf("skipped = %q, want %q", plan.Skipped, wantSkipped)
+	}
+}
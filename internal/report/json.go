@@ -0,0 +1,515 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/history"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/runner"
+)
+
+// Report is the machine-readable report of a complete run
+type Report struct {
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      time.Time      `json:"finished_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	NetboxURL       string         `json:"netbox_url"`
+	NAMURL          string         `json:"nam_url"`
+	ExitCode        int            `json:"exit_code"`
+	Checks          []config.Check `json:"checks"`
+	Results         []DCResult     `json:"results"`
+	Failures        []Failure      `json:"failures"`
+	Stretched       []Stretched    `json:"stretched_vxlans"`
+	Remediations    []Remediation  `json:"remediations"`
+}
+
+// DCResult is the report of the checks for a single DC
+type DCResult struct {
+	DCName             string            `json:"dc_name"`
+	Infra              string            `json:"infra"`
+	HasMismatches      bool              `json:"has_mismatches"`
+	Sources            Sources           `json:"sources"`
+	SkippedChecks      []string          `json:"skipped_checks"`
+	MovedVLANs         []MovedVLAN       `json:"moved_vlans"`
+	MisconfiguredVLANs []VxLAN           `json:"misconfigured_vlans"`
+	NameMismatches     []NameMismatch    `json:"name_mismatches"`
+	WrongPrefixes      []WrongPrefix     `json:"wrong_prefixes"`
+	StaleVLANs         []VLAN            `json:"stale_vlans"`
+	Duplicates         []Duplicate       `json:"duplicates"`
+	PrefixIssues       []PrefixIssue     `json:"prefix_issues"`
+	SubnetMismatches   []SubnetMismatch  `json:"subnet_mismatches"`
+	PrefixOverlaps     []PrefixOverlap   `json:"prefix_overlaps"`
+	WrongVRFs          []WrongVRF        `json:"wrong_vrfs"`
+	PolicyViolations   []PolicyViolation `json:"policy_violations"`
+	Suppressed         []Suppressed      `json:"suppressed"`
+	Drift              *Drift            `json:"drift,omitempty"`
+}
+
+// Suppressed is a finding hidden by a suppression in the configuration
+type Suppressed struct {
+	Check   string `json:"check"`
+	VLANID  int    `json:"vlan_id"`
+	Detail  string `json:"detail"`
+	Reason  string `json:"reason"`
+	Expires string `json:"expires,omitempty"`
+}
+
+// Drift classifies the findings of a DC against the previous run
+type Drift struct {
+	PreviousRun *time.Time       `json:"previous_run"`
+	New         []HistoryFinding `json:"new"`
+	Persisting  []HistoryFinding `json:"persisting"`
+	Resolved    []HistoryFinding `json:"resolved"`
+}
+
+// HistoryFinding is a finding tracked across runs
+type HistoryFinding struct {
+	Check     string    `json:"check"`
+	VLANID    int       `json:"vlan_id"`
+	Detail    string    `json:"detail"`
+	FirstSeen time.Time `json:"first_seen"`
+	AgeDays   int       `json:"age_days"`
+}
+
+// Sources holds the number of objects a DC result is based on
+type Sources struct {
+	NetboxVLANs    int `json:"netbox_vlans"`
+	NetboxPrefixes int `json:"netbox_prefixes"`
+	NAMVxLANs      int `json:"nam_vxlans"`
+	DCVxLANs       int `json:"dc_vxlans"`
+	NAMSubnets     int `json:"nam_subnets"`
+	InfraVLANs     int `json:"infra_vlans"`
+}
+
+// Failure is a check that could not be completed
+type Failure struct {
+	DCName       string `json:"dc_name"`
+	NetboxSiteID int    `json:"netbox_site_id"`
+	Infra        string `json:"infra"`
+	Error        string `json:"error"`
+}
+
+// Remediation is a change to Netbox planned or applied in fix mode
+type Remediation struct {
+	DCName     string `json:"dc_name"`
+	Kind       string `json:"kind"`
+	ObjectType string `json:"object_type"`
+	ObjectID   int    `json:"object_id"`
+	Object     string `json:"object"`
+	Field      string `json:"field"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Applied    bool   `json:"applied"`
+	Error      string `json:"error,omitempty"`
+}
+
+// VxLAN is a NAM VxLAN
+type VxLAN struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	VRF        string   `json:"vrf,omitempty"`
+	Containers []string `json:"containers"`
+}
+
+// VLAN is a Netbox VLAN
+type VLAN struct {
+	ID    int    `json:"id"`
+	VID   int    `json:"vid"`
+	Name  string `json:"name"`
+	Infra string `json:"infra"`
+	URL   string `json:"url"`
+}
+
+// Prefix is a Netbox prefix
+type Prefix struct {
+	ID     int    `json:"id"`
+	Prefix string `json:"prefix"`
+	VRF    string `json:"vrf"`
+	Infra  string `json:"infra"`
+	URL    string `json:"url"`
+}
+
+// MovedVLAN is a VLAN that was moved but not updated in NAM
+type MovedVLAN struct {
+	VxLAN      VxLAN  `json:"vxlan"`
+	NetboxVLAN VLAN   `json:"netbox_vlan"`
+	Rule       string `json:"rule"`
+}
+
+// NameMismatch is a VxLAN whose name differs from the Netbox VLAN with the
+// same VID. Similarity (0-1) is only set if similarity scoring is enabled.
+type NameMismatch struct {
+	VxLAN       VxLAN    `json:"vxlan"`
+	NetboxVLANs []VLAN   `json:"netbox_vlans"`
+	Similarity  *float64 `json:"similarity,omitempty"`
+}
+
+// Duplicate is a VID or name shared by several Netbox VLANs (kind "vid" or
+// "name"), or an ID shared by several NAM VxLANs (kind "vxlan_id")
+type Duplicate struct {
+	Kind        string  `json:"kind"`
+	VID         int     `json:"vid,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	NetboxVLANs []VLAN  `json:"netbox_vlans"`
+	NAMVxLANs   []VxLAN `json:"nam_vxlans"`
+}
+
+// Stretched is a VxLAN in several NAM containers that is missing in Netbox
+// for some of the checked DCs
+type Stretched struct {
+	VxLAN     VxLAN    `json:"vxlan"`
+	Present   []string `json:"present"`
+	Missing   []string `json:"missing"`
+	Unchecked []string `json:"unchecked"`
+}
+
+// PrefixIssue is a prefix with no VLAN ("no_vlan"), a VLAN outside the
+// site ("foreign_vlan") or a VLAN with another infra ("infra_mismatch")
+type PrefixIssue struct {
+	Kind   string `json:"kind"`
+	Prefix Prefix `json:"prefix"`
+	VLAN   *VLAN  `json:"vlan"`
+}
+
+// SubnetMismatch is a NAM subnet missing in Netbox ("missing_in_netbox"), a
+// Netbox prefix missing in NAM ("missing_in_nam") or a subnet and prefix of
+// the same VxLAN with different CIDRs ("cidr_mismatch")
+type SubnetMismatch struct {
+	Kind   string  `json:"kind"`
+	VxLAN  VxLAN   `json:"vxlan"`
+	Subnet *Subnet `json:"nam_subnet"`
+	Prefix *Prefix `json:"netbox_prefix"`
+}
+
+// PrefixOverlap is a pair of overlapping prefixes in the same VRF: an exact
+// "duplicate", prefixes with different infra ("cross_infra"), or an
+// "overlap" where Prefix is not a container
+type PrefixOverlap struct {
+	Kind   string `json:"kind"`
+	Prefix Prefix `json:"prefix"`
+	Other  Prefix `json:"other"`
+}
+
+// WrongVRF is a prefix on a VxLAN's VLAN outside the VxLAN's expected VRF
+type WrongVRF struct {
+	VxLAN       VxLAN  `json:"vxlan"`
+	Prefix      Prefix `json:"prefix"`
+	ExpectedVRF string `json:"expected_vrf"`
+}
+
+// PolicyViolation is a VLAN that does not meet a requirement ("status",
+// "tenant", "role" or "group") of a VLAN policy
+type PolicyViolation struct {
+	VLAN        VLAN     `json:"vlan"`
+	Requirement string   `json:"requirement"`
+	Actual      string   `json:"actual"`
+	Allowed     []string `json:"allowed"`
+}
+
+// Subnet is a NAM subnet
+type Subnet struct {
+	ID     int    `json:"id"`
+	Prefix string `json:"prefix"`
+}
+
+// WrongPrefix is a prefix with incorrect infra
+type WrongPrefix struct {
+	VxLAN  VxLAN  `json:"vxlan"`
+	Prefix Prefix `json:"prefix"`
+}
+
+// Build creates the report for a run summary
+func Build(summary *runner.Summary, cfg *config.Config) *Report {
+	report := &Report{
+		StartedAt:       summary.StartedAt,
+		FinishedAt:      summary.FinishedAt,
+		DurationSeconds: summary.FinishedAt.Sub(summary.StartedAt).Seconds(),
+		NetboxURL:       cfg.NetboxURL,
+		NAMURL:          cfg.NAMURL,
+		ExitCode:        summary.ExitCode(),
+		Checks:          summary.Checks,
+		Results:         []DCResult{},
+		Failures:        []Failure{},
+		Stretched:       []Stretched{},
+		Remediations:    []Remediation{},
+	}
+
+	for _, result := range summary.Results {
+		dc := NewDCResult(result, cfg.NetboxURL)
+		for _, drift := range summary.Drifts {
+			if drift.DCName == result.DCName && drift.Infra == result.Infra {
+				dc.Drift = newDrift(drift, summary.FinishedAt)
+			}
+		}
+		report.Results = append(report.Results, dc)
+	}
+
+	for _, f := range summary.Failures {
+		report.Failures = append(report.Failures, NewFailure(f))
+	}
+
+	for _, s := range summary.Stretched {
+		report.Stretched = append(report.Stretched, NewStretched(s))
+	}
+
+	for _, o := range summary.Remediations {
+		r := Remediation{
+			DCName:     o.DCName,
+			Kind:       o.Action.Kind,
+			ObjectType: o.Action.ObjectType,
+			ObjectID:   o.Action.ObjectID,
+			Object:     o.Action.Object,
+			Field:      o.Action.Field,
+			From:       o.Action.From,
+			To:         o.Action.To,
+			Applied:    o.Applied,
+		}
+		if o.Err != nil {
+			r.Error = o.Err.Error()
+		}
+		report.Remediations = append(report.Remediations, r)
+	}
+
+	return report
+}
+
+// WriteJSON writes the report for a run summary as indented JSON to w
+func WriteJSON(w io.Writer, summary *runner.Summary, cfg *config.Config) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Build(summary, cfg))
+}
+
+// NewDCResult converts a checker result to its report representation
+func NewDCResult(result *checker.Result, netboxURL string) DCResult {
+	dc := DCResult{
+		DCName:        result.DCName,
+		Infra:         result.Infra,
+		HasMismatches: result.HasMismatches,
+		Sources: Sources{
+			NetboxVLANs:    result.Sources.NetboxVLANs,
+			NetboxPrefixes: result.Sources.NetboxPrefixes,
+			NAMVxLANs:      result.Sources.NAMVxLANs,
+			DCVxLANs:       result.Sources.DCVxLANs,
+			NAMSubnets:     result.Sources.NAMSubnets,
+			InfraVLANs:     result.Sources.InfraVLANs,
+		},
+		SkippedChecks:      orEmpty(result.SkippedChecks),
+		MovedVLANs:         []MovedVLAN{},
+		MisconfiguredVLANs: []VxLAN{},
+		NameMismatches:     []NameMismatch{},
+		WrongPrefixes:      []WrongPrefix{},
+		StaleVLANs:         []VLAN{},
+		Duplicates:         []Duplicate{},
+		PrefixIssues:       []PrefixIssue{},
+		SubnetMismatches:   []SubnetMismatch{},
+		PrefixOverlaps:     []PrefixOverlap{},
+		WrongVRFs:          []WrongVRF{},
+		PolicyViolations:   []PolicyViolation{},
+		Suppressed:         []Suppressed{},
+	}
+
+	for _, mv := range result.MovedVLANs {
+		dc.MovedVLANs = append(dc.MovedVLANs, MovedVLAN{
+			VxLAN:      newVxLAN(mv.VxLAN),
+			NetboxVLAN: newVLAN(mv.NetboxVLAN, netboxURL),
+			Rule:       mv.Rule.String(),
+		})
+	}
+
+	for _, vxlan := range result.MisconfiguredVLANs {
+		dc.MisconfiguredVLANs = append(dc.MisconfiguredVLANs, newVxLAN(vxlan))
+	}
+
+	for _, nm := range result.NameMismatches {
+		mismatch := NameMismatch{
+			VxLAN:       newVxLAN(nm.VxLAN),
+			NetboxVLANs: []VLAN{},
+			Similarity:  nm.Similarity,
+		}
+		for _, vlan := range nm.NetboxVLANs {
+			mismatch.NetboxVLANs = append(mismatch.NetboxVLANs, newVLAN(vlan, netboxURL))
+		}
+		dc.NameMismatches = append(dc.NameMismatches, mismatch)
+	}
+
+	for _, wp := range result.WrongPrefixes {
+		dc.WrongPrefixes = append(dc.WrongPrefixes, WrongPrefix{
+			VxLAN:  newVxLAN(wp.VLAN),
+			Prefix: newPrefix(wp.Prefix, netboxURL),
+		})
+	}
+
+	for _, vlan := range result.StaleVLANs {
+		dc.StaleVLANs = append(dc.StaleVLANs, newVLAN(vlan, netboxURL))
+	}
+
+	for _, d := range result.Duplicates {
+		duplicate := Duplicate{
+			Kind:        d.Kind,
+			VID:         d.VID,
+			Name:        d.Name,
+			NetboxVLANs: []VLAN{},
+			NAMVxLANs:   []VxLAN{},
+		}
+		for _, vlan := range d.NetboxVLANs {
+			duplicate.NetboxVLANs = append(duplicate.NetboxVLANs, newVLAN(vlan, netboxURL))
+		}
+		for _, vxlan := range d.NAMVxLANs {
+			duplicate.NAMVxLANs = append(duplicate.NAMVxLANs, newVxLAN(vxlan))
+		}
+		dc.Duplicates = append(dc.Duplicates, duplicate)
+	}
+
+	for _, issue := range result.PrefixIssues {
+		pi := PrefixIssue{
+			Kind:   issue.Kind,
+			Prefix: newPrefix(issue.Prefix, netboxURL),
+		}
+		if issue.VLAN != nil {
+			vlan := newVLAN(*issue.VLAN, netboxURL)
+			pi.VLAN = &vlan
+		}
+		dc.PrefixIssues = append(dc.PrefixIssues, pi)
+	}
+
+	for _, m := range result.SubnetMismatches {
+		sm := SubnetMismatch{
+			Kind:  m.Kind,
+			VxLAN: newVxLAN(m.VxLAN),
+		}
+		if m.Subnet != nil {
+			sm.Subnet = &Subnet{ID: m.Subnet.ID, Prefix: m.Subnet.Prefix}
+		}
+		if m.Prefix != nil {
+			prefix := newPrefix(*m.Prefix, netboxURL)
+			sm.Prefix = &prefix
+		}
+		dc.SubnetMismatches = append(dc.SubnetMismatches, sm)
+	}
+
+	for _, o := range result.PrefixOverlaps {
+		dc.PrefixOverlaps = append(dc.PrefixOverlaps, PrefixOverlap{
+			Kind:   o.Kind,
+			Prefix: newPrefix(o.Prefix, netboxURL),
+			Other:  newPrefix(o.Other, netboxURL),
+		})
+	}
+
+	for _, wv := range result.WrongVRFs {
+		dc.WrongVRFs = append(dc.WrongVRFs, WrongVRF{
+			VxLAN:       newVxLAN(wv.VxLAN),
+			Prefix:      newPrefix(wv.Prefix, netboxURL),
+			ExpectedVRF: wv.Expected,
+		})
+	}
+
+	for _, pv := range result.PolicyViolations {
+		dc.PolicyViolations = append(dc.PolicyViolations, PolicyViolation{
+			VLAN:        newVLAN(pv.VLAN, netboxURL),
+			Requirement: pv.Requirement,
+			Actual:      pv.Actual,
+			Allowed:     orEmpty(pv.Allowed),
+		})
+	}
+
+	for _, sf := range result.Suppressed {
+		dc.Suppressed = append(dc.Suppressed, Suppressed{
+			Check:   sf.Finding.Check,
+			VLANID:  sf.Finding.VLANID,
+			Detail:  sf.Finding.Detail,
+			Reason:  sf.Suppression.Reason,
+			Expires: sf.Suppression.Expires,
+		})
+	}
+
+	return dc
+}
+
+// NewFailure converts a failed check to its report representation
+func NewFailure(f runner.Failure) Failure {
+	return Failure{
+		DCName:       f.Check.DCName,
+		NetboxSiteID: f.Check.NetboxSiteID,
+		Infra:        f.Check.Infra,
+		Error:        f.Err.Error(),
+	}
+}
+
+// NewStretched converts a stretched VxLAN to its report representation
+func NewStretched(s checker.StretchedVxLAN) Stretched {
+	return Stretched{
+		VxLAN:     newVxLAN(s.VxLAN),
+		Present:   orEmpty(s.Present),
+		Missing:   orEmpty(s.Missing),
+		Unchecked: orEmpty(s.Unchecked),
+	}
+}
+
+// newDrift converts a drift to its report representation
+func newDrift(drift history.Drift, now time.Time) *Drift {
+	convert := func(findings []history.Finding) []HistoryFinding {
+		converted := []HistoryFinding{}
+		for _, f := range findings {
+			converted = append(converted, HistoryFinding{
+				Check:     f.Check,
+				VLANID:    f.VLANID,
+				Detail:    f.Detail,
+				FirstSeen: f.FirstSeen,
+				AgeDays:   int(f.Age(now).Hours() / 24),
+			})
+		}
+		return converted
+	}
+
+	return &Drift{
+		PreviousRun: drift.PreviousRun,
+		New:         convert(drift.New),
+		Persisting:  convert(drift.Persisting),
+		Resolved:    convert(drift.Resolved),
+	}
+}
+
+// newVxLAN converts a NAM VxLAN to its report representation
+func newVxLAN(vxlan models.NAMVxLAN) VxLAN {
+	return VxLAN{
+		ID:         vxlan.ID,
+		Name:       vxlan.Name,
+		VRF:        vxlan.GetVRFName(),
+		Containers: vxlan.ContainerNames(),
+	}
+}
+
+// newVLAN converts a Netbox VLAN to its report representation
+func newVLAN(vlan models.NetboxVLAN, netboxURL string) VLAN {
+	return VLAN{
+		ID:    vlan.ID,
+		VID:   vlan.VID,
+		Name:  vlan.Name,
+		Infra: vlan.GetInfra(),
+		URL:   vlan.URL(netboxURL),
+	}
+}
+
+// newPrefix converts a Netbox prefix to its report representation
+func newPrefix(prefix models.NetboxPrefix, netboxURL string) Prefix {
+	return Prefix{
+		ID:     prefix.ID,
+		Prefix: prefix.Prefix,
+		VRF:    prefix.GetVRFName(),
+		Infra:  prefix.GetInfra(),
+		URL:    prefix.URL(netboxURL),
+	}
+}
+
+// orEmpty returns an empty slice instead of nil, so it is encoded as []
+func orEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
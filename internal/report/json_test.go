@@ -0,0 +1,139 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/runner"
+)
+
+func TestWriteJSON(t *testing.T) {
+	checks := []config.Check{
+		{NetboxSiteID: 1, Infra: "prod", DCName: "dc1"},
+		{NetboxSiteID: 2, Infra: "prod", DCName: "dc2"},
+	}
+	started := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	summary := &runner.Summary{
+		StartedAt:  started,
+		FinishedAt: started.Add(90 * time.Second),
+		Checks:     checks,
+		Results: []*checker.Result{{
+			DCName:             "dc1",
+			Infra:              "prod",
+			HasMismatches:      true,
+			MisconfiguredVLANs: []models.NAMVxLAN{{ID: 100, Name: "app-100"}},
+			Suppressed: []checker.SuppressedFinding{{
+				Finding:     checker.Finding{Check: checker.CheckStaleVLANs, VLANID: 200, Detail: "db-200"},
+				Suppression: config.Suppression{Reason: "lab", Expires: "2026-12-31"},
+			}},
+			SkippedChecks: []string{checker.CheckSubnetMismatches},
+		}},
+		Failures: []runner.Failure{{Check: checks[1], Err: errors.New("netbox is down")}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, summary, &config.Config{NetboxURL: "https://netbox.example"}); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	assertKeys(t, "report", got, []string{
+		"started_at", "finished_at", "duration_seconds", "netbox_url", "nam_url", "exit_code",
+		"checks", "results", "failures", "stretched_vxlans", "remediations",
+	})
+	if got["exit_code"] != float64(runner.ExitPartialRun) || got["duration_seconds"] != 90.0 {
+		t.Errorf("exit_code = %v, duration_seconds = %v, want %d and 90", got["exit_code"], got["duration_seconds"], runner.ExitPartialRun)
+	}
+	// Empty lists are encoded as [], not null
+	if stretched, ok := got["stretched_vxlans"].([]interface{}); !ok || len(stretched) != 0 {
+		t.Errorf("stretched_vxlans = %v, want []", got["stretched_vxlans"])
+	}
+
+	failures := got["failures"].([]interface{})
+	if len(failures) != 1 {
+		t.Fatalf("failures = %v, want one", failures)
+	}
+	assertKeys(t, "failure", failures[0].(map[string]interface{}), []string{"dc_name", "netbox_site_id", "infra", "error"})
+
+	results := got["results"].([]interface{})
+	if len(results) != 1 {
+		t.Fatalf("results = %v, want one", results)
+	}
+	dc := results[0].(map[string]interface{})
+	assertKeys(t, "result", dc, []string{
+		"dc_name", "infra", "has_mismatches", "sources", "skipped_checks",
+		"moved_vlans", "misconfigured_vlans", "name_mismatches", "wrong_prefixes", "stale_vlans",
+		"duplicates", "prefix_issues", "subnet_mismatches", "prefix_overlaps", "wrong_vrfs",
+		"policy_violations", "suppressed",
+	})
+	if skipped := dc["skipped_checks"]; !reflect.DeepEqual(skipped, []interface{}{"subnet_mismatch"}) {
+		t.Errorf("skipped_checks = %v, want [subnet_mismatch]", skipped)
+	}
+	if misconfigured := dc["misconfigured_vlans"].([]interface{}); len(misconfigured) != 1 {
+		t.Errorf("misconfigured_vlans = %v, want one", misconfigured)
+	}
+	if moved, ok := dc["moved_vlans"].([]interface{}); !ok || len(moved) != 0 {
+		t.Errorf("moved_vlans = %v, want []", dc["moved_vlans"])
+	}
+
+	suppressed := dc["suppressed"].([]interface{})
+	if len(suppressed) != 1 {
+		t.Fatalf("suppressed = %v, want one", suppressed)
+	}
+	want := map[string]interface{}{
+		"check":   "stale_vlan",
+		"vlan_id": 200.0,
+		"detail":  "db-200",
+		"reason":  "lab",
+		"expires": "2026-12-31",
+	}
+	if !reflect.DeepEqual(suppressed[0], want) {
+		t.Errorf("suppressed = %v, want %v", suppressed[0], want)
+	}
+}
+
+func TestWriteJSONWithoutSkippedChecks(t *testing.T) {
+	summary := &runner.Summary{
+		Results: []*checker.Result{{DCName: "dc1", Infra: "prod"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, summary, &config.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ExitCode != runner.ExitOK {
+		t.Errorf("exit_code = %d, want %d", got.ExitCode, runner.ExitOK)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"skipped_checks": []`)) {
+		t.Errorf("skipped_checks is not an empty list:\n%s", buf.String())
+	}
+}
+
+// assertKeys checks that an object has exactly the keys want
+func assertKeys(t *testing.T, what string, object map[string]interface{}, want []string) {
+	t.Helper()
+	var got []string
+	for key := range object {
+		got = append(got, key)
+	}
+	slices.Sort(got)
+	want = slices.Sorted(slices.Values(want))
+	if !slices.Equal(got, want) {
+		t.Errorf("%s keys = %v, want %v", what, got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/metrics"
+)
+
+// lastSuccessMetric is the timestamp of the last run where every check
+// completed. It is carried over from the previous textfile on failed runs.
+const lastSuccessMetric = "dcn_infra_check_last_success_timestamp_seconds"
+
+// RecordMetrics records the findings and outcome of the run in the default
+// metrics registry
+func (s *Summary) RecordMetrics() {
+	for _, result := range s.Results {
+		counts := make(map[string]int)
+		for _, f := range result.Findings() {
+			counts[f.Check]++
+		}
+
+		for _, check := range checker.CheckTypes {
+			metrics.SetGauge("dcn_infra_check_findings",
+				"Findings per DC, infra and check type in the last run",
+				metrics.Labels{"dc": result.DCName, "infra": result.Infra, "check": check},
+				float64(counts[check]))
+		}
+
+		metrics.SetGauge("dcn_infra_check_suppressed_findings",
+			"Findings hidden by a suppression per DC and infra in the last run",
+			metrics.Labels{"dc": result.DCName, "infra": result.Infra},
+			float64(len(result.Suppressed)))
+	}
+
+	failed := make(map[int]bool)
+	for i, check := range s.Checks {
+		for _, f := range s.Failures {
+			if f.Check == check {
+				failed[i] = true
+			}
+		}
+	}
+
+	for i, check := range s.Checks {
+		up := 1.0
+		if failed[i] {
+			up = 0
+		}
+		metrics.SetGauge("dcn_infra_check_dc_up",
+			"Whether the check for a DC completed in the last run",
+			metrics.Labels{"dc": check.DCName, "infra": check.Infra}, up)
+	}
+
+	metrics.SetGauge("dcn_infra_check_stretched_vxlans_incomplete",
+		"Stretched VxLANs missing in Netbox for some of their DCs in the last run",
+		nil, float64(len(s.Stretched)))
+
+	metrics.SetGauge("dcn_infra_check_run_duration_seconds",
+		"Duration of the last run", nil, s.FinishedAt.Sub(s.StartedAt).Seconds())
+	metrics.SetGauge("dcn_infra_check_last_run_timestamp_seconds",
+		"Timestamp of the last run", nil, float64(s.FinishedAt.Unix()))
+
+	if s.ExitCode() == ExitOK {
+		metrics.SetGauge(lastSuccessMetric,
+			"Timestamp of the last run where every check completed", nil, float64(s.FinishedAt.Unix()))
+	}
+}
+
+// ExportMetrics pushes the default metrics registry to the Pushgateway
+// and/or writes it to the textfile, depending on the configuration. Both
+// are attempted even if one fails, and all failures are returned.
+func ExportMetrics(cfg config.MetricsConfig) error {
+	return exportMetrics(metrics.Default, cfg)
+}
+
+// exportMetrics exports the registry as described for ExportMetrics
+func exportMetrics(registry *metrics.Registry, cfg config.MetricsConfig) error {
+	// Both exports are attempted, a failed push must not stop the textfile
+	var errs []error
+
+	if cfg.PushgatewayURL != "" {
+		job := cfg.Job
+		if job == "" {
+			job = "dcn-netbox-infra-check"
+		}
+		if err := registry.Push(cfg.PushgatewayURL, job); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if cfg.TextfilePath != "" {
+		if _, ok := registry.Value(lastSuccessMetric, nil); !ok {
+			if last, ok := metrics.ReadTextfileValue(cfg.TextfilePath, lastSuccessMetric); ok {
+				registry.SetGauge(lastSuccessMetric,
+					"Timestamp of the last run where every check completed", nil, last)
+			}
+		}
+		if err := registry.WriteTextfile(cfg.TextfilePath); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write metrics textfile: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/metrics"
+)
+
+func TestExportMetricsKeepsLastSuccess(t *testing.T) {
+	cfg := config.MetricsConfig{TextfilePath: filepath.Join(t.TempDir(), "check.prom")}
+
+	// A successful run writes its timestamp
+	registry := metrics.NewRegistry()
+	registry.SetGauge(lastSuccessMetric, "Last success", nil, 1000)
+	if err := exportMetrics(registry, cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertLastSuccess(t, cfg.TextfilePath, 1000)
+
+	// A failed run in a new process carries it over from the textfile
+	if err := exportMetrics(metrics.NewRegistry(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertLastSuccess(t, cfg.TextfilePath, 1000)
+
+	// The next successful run replaces it
+	registry = metrics.NewRegistry()
+	registry.SetGauge(lastSuccessMetric, "Last success", nil, 2000)
+	if err := exportMetrics(registry, cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertLastSuccess(t, cfg.TextfilePath, 2000)
+}
+
+func TestExportMetricsWithoutPreviousTextfile(t *testing.T) {
+	cfg := config.MetricsConfig{TextfilePath: filepath.Join(t.TempDir(), "check.prom")}
+
+	if err := exportMetrics(metrics.NewRegistry(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := metrics.ReadTextfileValue(cfg.TextfilePath, lastSuccessMetric); ok {
+		t.Error("a failed first run wrote a last success timestamp")
+	}
+}
+
+func TestExportMetricsPushFailure(t *testing.T) {
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer pushgateway.Close()
+
+	cfg := config.MetricsConfig{
+		PushgatewayURL: pushgateway.URL,
+		TextfilePath:   filepath.Join(t.TempDir(), "check.prom"),
+	}
+	registry := metrics.NewRegistry()
+	registry.SetGauge(lastSuccessMetric, "Last success", nil, 1000)
+
+	if err := exportMetrics(registry, cfg); err == nil {
+		t.Error("a failed push was not reported")
+	}
+	assertLastSuccess(t, cfg.TextfilePath, 1000)
+}
+
+// assertLastSuccess checks the last success timestamp in the textfile
+func assertLastSuccess(t *testing.T, path string, want float64) {
+	t.Helper()
+	got, ok := metrics.ReadTextfileValue(path, lastSuccessMetric)
+	if !ok || got != want {
+		t.Errorf("last success = %v, %v, want %v", got, ok, want)
+	}
+}
@@ -0,0 +1,438 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/checker"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/client"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/history"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/remediation"
+)
+
+// Exit codes reported by the application after a run
+const (
+	ExitOK         = 0
+	ExitPartialRun = 2
+	ExitAllFailed  = 3
+)
+
+// Runner runs the configured DC checks
+type Runner struct {
+	cfg    *config.Config
+	netbox client.NetboxSource
+	nam    client.NAMSource
+	out    io.Writer
+	opts   Options
+}
+
+// Options controls optional behaviour of a run
+type Options struct {
+	// Fix computes a remediation plan for each result and prints it
+	Fix bool
+	// ApplyFixes applies the remediation plan to Netbox instead of only
+	// printing it. Only used together with Fix.
+	ApplyFixes bool
+	// Replay marks a run on recorded data. Nothing is reported to ESM or
+	// Slack, written to the history or changed in Netbox during a replay.
+	Replay bool
+	// At is the time suppressions are evaluated at, the current time if
+	// zero. A replay uses the time of the recorded run.
+	At time.Time
+}
+
+// Summary holds the outcome of a complete run
+type Summary struct {
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	Checks       []config.Check
+	Results      []*checker.Result
+	Failures     []Failure
+	Stretched    []checker.StretchedVxLAN
+	Remediations []remediation.Outcome
+	Drifts       []history.Drift
+}
+
+// input holds the data fetched for a single check
+type input struct {
+	NetboxVLANs    []models.NetboxVLAN
+	NetboxPrefixes []models.NetboxPrefix
+	NAMVxLANs      []models.NAMVxLAN
+	NAMSubnets     []models.NAMSubnet
+}
+
+// readOnlyNetbox rejects every update, for Netbox sources that cannot write
+type readOnlyNetbox struct{}
+
+func (readOnlyNetbox) PatchVLAN(int, map[string]interface{}) error {
+	return errors.New("the Netbox source is read-only")
+}
+
+func (readOnlyNetbox) PatchPrefix(int, map[string]interface{}) error {
+	return errors.New("the Netbox source is read-only")
+}
+
+// Failure describes a check that could not be completed. There is at most
+// one failure per check.
+type Failure struct {
+	Check config.Check
+	Err   error
+}
+
+// New creates a new Runner writing its report to out
+func New(cfg *config.Config, netbox client.NetboxSource, nam client.NAMSource, out io.Writer, opts Options) *Runner {
+	return &Runner{
+		cfg:    cfg,
+		netbox: netbox,
+		nam:    nam,
+		out:    out,
+		opts:   opts,
+	}
+}
+
+// Run runs every configured check. A failing check is recorded in the
+// summary and does not stop the remaining checks.
+func (r *Runner) Run() *Summary {
+	summary := &Summary{
+		StartedAt: time.Now(),
+		Checks:    r.cfg.Checks,
+	}
+
+	// The history is read once per run
+	var store *history.Store
+	if r.cfg.HistoryPath != "" && !r.opts.Replay {
+		store = history.Open(r.cfg.HistoryPath)
+	}
+
+	for _, check := range r.cfg.Checks {
+		fmt.Fprintf(r.out, "\n\n")
+		fmt.Fprintf(r.out, "==================================\n")
+		fmt.Fprintf(r.out, "Sjekker datasenter %s\n", strings.ToUpper(check.DCName))
+		fmt.Fprintf(r.out, "==================================\n\n")
+
+		result, err := r.runCheck(check, summary, store)
+		if result != nil {
+			summary.Results = append(summary.Results, result)
+		}
+		if err != nil {
+			log.Printf("✗ Check for %s (site %d, infra %s) failed: %v", check.DCName, check.NetboxSiteID, check.Infra, err)
+			summary.Failures = append(summary.Failures, Failure{
+				Check: check,
+				Err:   err,
+			})
+		}
+	}
+
+	// Stretched VxLANs can only be compared once every DC is checked
+	summary.Stretched = checker.CheckStretchedVxLANs(summary.Results)
+	if len(summary.Stretched) > 0 {
+		fmt.Fprintf(r.out, "\n\n")
+		fmt.Fprint(r.out, checker.FormatStretchedVxLANs(summary.Stretched))
+	}
+
+	summary.FinishedAt = time.Now()
+	summary.RecordMetrics()
+
+	return summary
+}
+
+// runCheck fetches the data for a single DC, runs the checks and reports
+// any mismatches. The result is returned even if reporting fails. The
+// result is compared with the history in store unless it is nil.
+func (r *Runner) runCheck(check config.Check, summary *Summary, store *history.Store) (*checker.Result, error) {
+	data, err := r.fetch(check)
+	if err != nil {
+		return nil, err
+	}
+
+	// Perform checks
+	result := checker.Check(
+		check.DCName,
+		check.Infra,
+		data.NetboxVLANs,
+		data.NetboxPrefixes,
+		data.NAMVxLANs,
+		data.NAMSubnets,
+		r.cfg,
+	)
+	at := r.opts.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	result.ApplySuppressions(r.cfg, at)
+
+	// Print results
+	fmt.Fprint(r.out, result.Output)
+
+	// Compare with the previous run. A broken history must not stop the
+	// check from being reported.
+	if store != nil {
+		drift, err := recordHistory(store, result)
+		if err != nil {
+			log.Printf("✗ Failed to update run history for %s: %v", check.DCName, err)
+		} else {
+			summary.Drifts = append(summary.Drifts, *drift)
+			fmt.Fprint(r.out, drift.Format(time.Now()))
+		}
+	}
+
+	// Fix mechanical findings in Netbox. The applied fixes are reported to
+	// ESM.
+	var applied string
+	if r.opts.Fix && result.HasMismatches {
+		fixes := r.remediate(result)
+		summary.Remediations = append(summary.Remediations, fixes.outcomes...)
+		fmt.Fprint(r.out, fixes.output)
+		if !fixes.dryRun {
+			applied = fixes.output
+		}
+	}
+
+	if r.opts.Replay {
+		return result, nil
+	}
+
+	// Send to Slack if there are mismatches
+	// if result.HasMismatches {
+	// 	slackClient := client.NewSlackClient(r.cfg.SlackWebhook, r.cfg.SlackRetry)
+	// 	if err := slackClient.Send(result); err != nil {
+	// 		log.Printf("✗ Failed to send Slack notification: %v", err)
+	// 	}
+	// }
+
+	// Report to ESM, or close the open request if the DC is clean
+	if err := r.reportESM(result, check, applied); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// fetch returns the Netbox and NAM data for a check
+func (r *Runner) fetch(check config.Check) (*input, error) {
+	// Fetch NAM VxLANs for this DC
+	namVxLANs, err := r.nam.FetchVxLANs(check.DCName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch NAM VxLANs: %w", err)
+	}
+
+	// The subnets are only needed for the subnet check, which is skipped
+	// if they cannot be fetched
+	namSubnets, err := r.nam.FetchSubnets(check.DCName)
+	if err != nil {
+		log.Printf("✗ Failed to fetch NAM subnets for %s, skipping the subnet check: %v", check.DCName, err)
+		namSubnets = nil
+	} else if namSubnets == nil {
+		namSubnets = []models.NAMSubnet{}
+	}
+
+	// Fetch Netbox data for this site
+	netboxVLANs, err := r.netbox.FetchVLANs(check.NetboxSiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Netbox VLANs for site %d: %w", check.NetboxSiteID, err)
+	}
+
+	netboxPrefixes, err := r.netbox.FetchPrefixes(check.NetboxSiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Netbox prefixes for site %d: %w", check.NetboxSiteID, err)
+	}
+
+	data := &input{
+		NetboxVLANs:    netboxVLANs,
+		NetboxPrefixes: netboxPrefixes,
+		NAMVxLANs:      namVxLANs,
+		NAMSubnets:     namSubnets,
+	}
+
+	return data, validate(check, data)
+}
+
+// validate rejects data that cannot produce a meaningful result
+func validate(check config.Check, data *input) error {
+	if len(data.NAMVxLANs) == 0 {
+		return fmt.Errorf("no NAM VxLANs fetched for %s - check API URL, token or DC name", check.DCName)
+	}
+
+	if len(data.NetboxVLANs) == 0 {
+		return fmt.Errorf("no Netbox VLANs fetched for site %d - check API URL or token", check.NetboxSiteID)
+	}
+
+	return nil
+}
+
+// recordHistory appends the findings of a result to the run history and
+// returns how they changed since the previous run
+func recordHistory(store *history.Store, result *checker.Result) (*history.Drift, error) {
+	previous, err := store.Previous(result.DCName, result.Infra)
+	if err != nil {
+		return nil, err
+	}
+
+	record, drift := history.Compare(previous, result, time.Now())
+	if err := store.Append(record); err != nil {
+		return nil, err
+	}
+
+	return &drift, nil
+}
+
+// remediationRun holds the outcome of remediating a single result
+type remediationRun struct {
+	outcomes []remediation.Outcome
+	output   string
+	dryRun   bool
+}
+
+// remediate builds the remediation plan for a result and applies it if
+// the run was confirmed with ApplyFixes
+func (r *Runner) remediate(result *checker.Result) remediationRun {
+	plan := remediation.BuildPlan(result)
+	dryRun := !r.opts.ApplyFixes || r.opts.Replay
+
+	var outcomes []remediation.Outcome
+	if dryRun {
+		outcomes = plan.DryRun()
+	} else {
+		writer, ok := r.netbox.(client.NetboxWriter)
+		if !ok {
+			writer = readOnlyNetbox{}
+		}
+		outcomes = plan.Apply(writer)
+		for _, o := range outcomes {
+			if o.Err != nil {
+				log.Printf("✗ Failed to update Netbox %s %d: %v", o.Action.ObjectType, o.Action.ObjectID, o.Err)
+			}
+		}
+	}
+
+	return remediationRun{
+		outcomes: outcomes,
+		output:   remediation.FormatOutcomes(plan, outcomes, dryRun),
+		dryRun:   dryRun,
+	}
+}
+
+// reportESM reports a result to ESM. An open request for the DC is updated
+// with a comment if the findings have changed and left alone if they are the
+// same or some checks were skipped. A new request is only created if none is
+// open. When the DC has no findings the open request is resolved. Applied
+// fixes are added to a new request and posted as a comment on an open one.
+func (r *Runner) reportESM(result *checker.Result, check config.Check, fixes string) error {
+	if r.cfg.ESMURL == "" {
+		log.Printf("ESM is not configured, skipping ESM report for %s", check.DCName)
+		return nil
+	}
+
+	esmClient := client.NewESMClient(r.cfg.ESMURL, r.cfg.ESMUser, r.cfg.ESMPassword, r.cfg.ESMTenantID, r.cfg.ESMRetry)
+
+	if err := esmClient.Authenticate(); err != nil {
+		return fmt.Errorf("failed to authenticate to ESM: %w", err)
+	}
+
+	existing, err := esmClient.FindOpenRequest(check.DCName, check.Infra)
+	if err != nil {
+		return fmt.Errorf("failed to look up open ESM request: %w", err)
+	}
+
+	// Every change made in Netbox is posted, even if the findings of the
+	// open request are unchanged
+	if existing != nil && fixes != "" {
+		if err := esmClient.AddComment(existing.ID, fixes); err != nil {
+			return fmt.Errorf("failed to comment on ESM request %s: %w", existing.ID, err)
+		}
+		fmt.Fprintf(r.out, "✓ La til utbedringene i Netbox på sak %s i ESM\n", existing.ID)
+	}
+
+	// Findings of skipped checks are unknown, not resolved, so an open
+	// request is left as it is until every check has run again
+	if existing != nil && len(result.SkippedChecks) > 0 {
+		fmt.Fprintf(r.out, "! Sak %s i ESM er ikke endret, noen sjekker ble hoppet over\n", existing.ID)
+		return nil
+	}
+
+	if !result.HasMismatches {
+		if existing == nil {
+			return nil
+		}
+
+		request := esmClient.CreateCloseRequest(existing.ID, result)
+		if err := esmClient.SendRequest(request); err != nil {
+			return fmt.Errorf("failed to close ESM request %s: %w", existing.ID, err)
+		}
+		fmt.Fprintf(r.out, "✓ Lukket sak %s i ESM, ingen avvik gjenstår\n", existing.ID)
+		return nil
+	}
+
+	if existing == nil {
+		withFixes := *result
+		withFixes.Output += fixes
+		request := esmClient.CreateRequest(&withFixes, check.DCName, check.Infra, r.cfg)
+		if err := esmClient.SendRequest(request); err != nil {
+			return fmt.Errorf("failed to send ESM request: %w", err)
+		}
+		fmt.Fprintf(r.out, "✓ Opprettet ny sak i ESM\n")
+		return nil
+	}
+
+	if existing.Fingerprint() == result.Fingerprint() {
+		fmt.Fprintf(r.out, "✓ Sak %s i ESM er allerede oppdatert\n", existing.ID)
+		return nil
+	}
+
+	comment := fmt.Sprintf("Avvikene er endret siden forrige kjøring:\n\n%s", result.Output)
+	if err := esmClient.AddComment(existing.ID, comment); err != nil {
+		return fmt.Errorf("failed to comment on ESM request %s: %w", existing.ID, err)
+	}
+
+	request := esmClient.CreateUpdateRequest(existing.ID, result)
+	if err := esmClient.SendRequest(request); err != nil {
+		return fmt.Errorf("failed to update ESM request %s: %w", existing.ID, err)
+	}
+	fmt.Fprintf(r.out, "✓ Oppdaterte sak %s i ESM med nye avvik\n", existing.ID)
+
+	return nil
+}
+
+// ExitCode returns the process exit code for the run: ExitOK if every check
+// completed, ExitAllFailed if none did and ExitPartialRun otherwise
+func (s *Summary) ExitCode() int {
+	failed := len(s.Failures)
+	switch {
+	case failed == 0:
+		return ExitOK
+	case failed == len(s.Checks):
+		return ExitAllFailed
+	default:
+		return ExitPartialRun
+	}
+}
+
+// Print writes a short summary of the run to w
+func (s *Summary) Print(w io.Writer) {
+	failed := len(s.Failures)
+
+	if failed == 0 {
+		fmt.Fprintf(w, "\n======================\n")
+		fmt.Fprintf(w, "Alle sjekker fullført!\n")
+		fmt.Fprintf(w, "======================\n\n")
+		return
+	}
+
+	fmt.Fprintf(w, "\n%s\n", strings.Repeat("=", 50))
+	if failed == len(s.Checks) {
+		fmt.Fprintf(w, "Ingen sjekker fullført (%d av %d feilet)\n", failed, len(s.Checks))
+	} else {
+		fmt.Fprintf(w, "Delvis kjøring: %d av %d sjekker feilet\n", failed, len(s.Checks))
+	}
+	fmt.Fprintf(w, "%s\n", strings.Repeat("=", 50))
+	for _, f := range s.Failures {
+		fmt.Fprintf(w, "✗ %s (site %d, infra %s): %v\n", f.Check.DCName, f.Check.NetboxSiteID, f.Check.Infra, f.Err)
+	}
+	fmt.Fprintf(w, "\n")
+}
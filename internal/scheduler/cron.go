@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron schedule with the standard five fields:
+// minute, hour, day of month, month and day of week
+type Schedule struct {
+	minute     map[int]bool
+	hour       map[int]bool
+	dayOfMonth map[int]bool
+	month      map[int]bool
+	dayOfWeek  map[int]bool
+
+	// A restricted day of month or day of week field matches if either of
+	// them matches, as in cron
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// field describes the allowed range of a cron field
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var (
+	minuteField     = field{"minute", 0, 59}
+	hourField       = field{"hour", 0, 23}
+	dayOfMonthField = field{"day of month", 1, 31}
+	monthField      = field{"month", 1, 12}
+	dayOfWeekField  = field{"day of week", 0, 7}
+)
+
+// Parse parses a cron expression such as "0 8 * * 1-5". Each field accepts
+// '*', single values, ranges 'a-b', steps '*/n' or 'a-b/n' and comma
+// separated lists of these. Day of week 0 and 7 are both Sunday.
+func Parse(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron schedule %q must have 5 fields, got %d", spec, len(fields))
+	}
+
+	s := &Schedule{
+		anyDayOfMonth: strings.HasPrefix(fields[2], "*"),
+		anyDayOfWeek:  strings.HasPrefix(fields[4], "*"),
+	}
+
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dayOfMonth, err = parseField(fields[2], dayOfMonthField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dayOfWeek, err = parseField(fields[4], dayOfWeekField); err != nil {
+		return nil, err
+	}
+	if s.dayOfWeek[7] {
+		s.dayOfWeek[0] = true
+	}
+
+	return s, nil
+}
+
+// parseField parses a single cron field into the set of matching values
+func parseField(expr string, f field) (map[int]bool, error) {
+	values := make(map[int]bool)
+
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+			rangeExpr, step = part[:i], n
+		}
+
+		start, end := f.min, f.max
+		if rangeExpr != "*" {
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value in %s field %q", f.name, part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value in %s field %q", f.name, part)
+				}
+			} else if step > 1 {
+				end = f.max
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return nil, fmt.Errorf("%s field %q is out of range %d-%d", f.name, part, f.min, f.max)
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// Next returns the first time after t matching the schedule, or the zero
+// time if there is none within the next five years. The schedule follows
+// the wall clock of t's location: a time skipped by a daylight saving
+// change runs as much later as the clock moved, and a time repeated by a
+// change runs once.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	// Walk the wall clock as UTC, which has no daylight saving changes
+	w := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	limit := w.AddDate(5, 0, 0)
+
+	for w.Before(limit) {
+		if !s.month[int(w.Month())] {
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.matchesDay(w) {
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.hour[w.Hour()] {
+			w = w.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !s.minute[w.Minute()] {
+			w = w.Add(time.Minute)
+			continue
+		}
+
+		next := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+		if next.After(t) {
+			return next
+		}
+		w = w.Add(time.Minute)
+	}
+
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches the day of month and day
+// of week fields
+func (s *Schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dayOfMonth[t.Day()]
+	dowMatch := s.dayOfWeek[int(t.Weekday())]
+
+	switch {
+	case s.anyDayOfMonth && s.anyDayOfWeek:
+		return true
+	case s.anyDayOfMonth:
+		return dowMatch
+	case s.anyDayOfWeek:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
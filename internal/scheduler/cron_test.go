@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"0 8 * *",
+		"0 8 * * * *",
+		"60 8 * * *",
+		"0 24 * * *",
+		"0 8 0 * *",
+		"0 8 32 * *",
+		"0 8 * 13 *",
+		"0 8 * * 8",
+		"-1 8 * * *",
+		"5-1 8 * * *",
+		"*/0 8 * * *",
+		"*/x 8 * * *",
+		"1-5/-1 8 * * *",
+		"a 8 * * *",
+		"1,,2 8 * * *",
+		"*-5 8 * * *",
+	}
+	for _, spec := range tests {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		expr string
+		f    field
+		want []int
+	}{
+		{"*", hourField, seq(0, 23, 1)},
+		{"5", minuteField, []int{5}},
+		{"1-5", dayOfWeekField, []int{1, 2, 3, 4, 5}},
+		{"*/15", minuteField, []int{0, 15, 30, 45}},
+		{"10/20", minuteField, []int{10, 30, 50}},
+		{"1-10/3", dayOfMonthField, []int{1, 4, 7, 10}},
+		{"1,3,5", monthField, []int{1, 3, 5}},
+		{"1-3,10-12", monthField, []int{1, 2, 3, 10, 11, 12}},
+		{"0-59/30,45", minuteField, []int{0, 30, 45}},
+		{"31", dayOfMonthField, []int{31}},
+	}
+	for _, tt := range tests {
+		values, err := parseField(tt.expr, tt.f)
+		if err != nil {
+			t.Errorf("parseField(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if len(values) != len(tt.want) {
+			t.Errorf("parseField(%q) = %v, want %v", tt.expr, values, tt.want)
+			continue
+		}
+		for _, v := range tt.want {
+			if !values[v] {
+				t.Errorf("parseField(%q) = %v, want %v", tt.expr, values, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		from string
+		want string
+	}{
+		{"later today", "0 8 * * *", "2026-10-16 07:59", "2026-10-16 08:00"},
+		{"exactly now runs next time", "0 8 * * *", "2026-10-16 08:00", "2026-10-17 08:00"},
+		{"seconds are ignored", "0 8 * * *", "2026-10-16 07:59:59", "2026-10-16 08:00"},
+		{"every 15 minutes", "*/15 * * * *", "2026-10-16 10:16", "2026-10-16 10:30"},
+		{"hour range", "0 9-17 * * *", "2026-10-16 17:01", "2026-10-17 09:00"},
+		{"list of hours", "30 6,18 * * *", "2026-10-16 07:00", "2026-10-16 18:30"},
+		{"weekdays skip the weekend", "0 8 * * 1-5", "2026-10-16 09:00", "2026-10-19 08:00"},
+		{"sunday as 7", "0 8 * * 7", "2026-10-16 09:00", "2026-10-18 08:00"},
+		{"sunday as 0", "0 8 * * 0", "2026-10-16 09:00", "2026-10-18 08:00"},
+		{"end of month", "0 0 1 * *", "2026-01-31 12:00", "2026-02-01 00:00"},
+		{"31st skips short months", "0 8 31 * *", "2026-04-01 00:00", "2026-05-31 08:00"},
+		{"29 february", "0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		{"end of year", "0 0 * * *", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"month list", "0 8 1 3,9 *", "2026-03-02 00:00", "2026-09-01 08:00"},
+		// With both day fields restricted either of them matches
+		{"day of month or week, week first", "0 8 20 * 1", "2026-10-16 09:00", "2026-10-19 08:00"},
+		{"day of month or week, month first", "0 8 17 * 1", "2026-10-16 09:00", "2026-10-17 08:00"},
+		// A star with a step counts as unrestricted, as in cron
+		{"day of month step with weekday", "0 8 */1 * 1", "2026-10-16 09:00", "2026-10-19 08:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, want := parseTime(t, tt.from, time.UTC), parseTime(t, tt.want, time.UTC)
+			if got := s.Next(from); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", from, got, want)
+			}
+		})
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want the zero time for 31 February", got)
+	}
+}
+
+func TestNextDaylightSaving(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from string
+		want []string
+	}{
+		// 2026-03-29 02:00 CET jumps to 03:00 CEST
+		{"daily across spring forward", "0 8 * * *", "2026-03-28 09:00", []string{"2026-03-29 08:00 +0200", "2026-03-30 08:00 +0200"}},
+		{"skipped time runs after the change", "30 2 * * *", "2026-03-28 03:00", []string{"2026-03-29 03:30 +0200", "2026-03-30 02:30 +0200"}},
+		{"hourly across spring forward", "0 * * * *", "2026-03-29 01:30", []string{"2026-03-29 03:00 +0200", "2026-03-29 04:00 +0200"}},
+		// 2026-10-25 03:00 CEST falls back to 02:00 CET
+		{"daily across fall back", "0 8 * * *", "2026-10-24 09:00", []string{"2026-10-25 08:00 +0100", "2026-10-26 08:00 +0100"}},
+		{"repeated time runs once", "30 2 * * *", "2026-10-24 03:00", []string{"2026-10-25 02:30 +0100", "2026-10-26 02:30 +0100"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at := parseTime(t, tt.from, oslo)
+			for _, w := range tt.want {
+				want, err := time.Parse("2006-01-02 15:04 -0700", w)
+				if err != nil {
+					t.Fatal(err)
+				}
+				next := s.Next(at)
+				if !next.Equal(want) {
+					t.Fatalf("Next(%s) = %s, want %s", at, next, want)
+				}
+				at = next
+			}
+		})
+	}
+}
+
+// parseTime parses a wall clock time in loc, with or without seconds
+func parseTime(t *testing.T, value string, loc *time.Location) time.Time {
+	t.Helper()
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if at, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return at
+		}
+	}
+	t.Fatalf("invalid time %q", value)
+	return time.Time{}
+}
+
+// seq returns the values from start to end with the given step
+func seq(start, end, step int) []int {
+	var values []int
+	for v := start; v <= end; v += step {
+		values = append(values, v)
+	}
+	return values
+}
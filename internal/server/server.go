@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/metrics"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/report"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/runner"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/scheduler"
+)
+
+// Server runs checks on a schedule and serves the latest results over HTTP
+type Server struct {
+	cfg    *config.Config
+	runner *runner.Runner
+
+	// runMu serialises runs, mu protects the state below
+	runMu   sync.Mutex
+	mu      sync.RWMutex
+	lastRun *runInfo
+	results map[string]dcResult
+}
+
+// runInfo describes a completed run
+type runInfo struct {
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	ExitCode   int                `json:"exit_code"`
+	Stretched  []report.Stretched `json:"stretched_vxlans"`
+}
+
+// dcResult is the latest state of a single check. If the latest run failed
+// for the check, Result holds the last successful result and Error the
+// failure.
+type dcResult struct {
+	DCName    string           `json:"dc_name"`
+	Infra     string           `json:"infra"`
+	CheckedAt *time.Time       `json:"checked_at"`
+	Result    *report.DCResult `json:"result"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// resultsResponse is the response of the results endpoints
+type resultsResponse struct {
+	LastRun *runInfo   `json:"last_run"`
+	Results []dcResult `json:"results"`
+}
+
+// New creates a server running checks with the given runner
+func New(cfg *config.Config, r *runner.Runner) *Server {
+	return &Server{
+		cfg:     cfg,
+		runner:  r,
+		results: make(map[string]dcResult),
+	}
+}
+
+// Handler returns the HTTP API of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.HandleFunc("GET /results", s.handleResults)
+	mux.HandleFunc("GET /results/{dc}", s.handleDCResults)
+	mux.HandleFunc("POST /run", s.handleRun)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+// Schedule runs the checks every time the schedule fires until ctx is done
+func (s *Server) Schedule(ctx context.Context, schedule *scheduler.Schedule) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("✗ Schedule never fires again, stopping scheduler")
+			return
+		}
+		log.Printf("Next scheduled run at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.Run()
+		}
+	}
+}
+
+// Run runs every check and stores the results. If a run is already in
+// progress it waits for it to finish before starting a new one.
+func (s *Server) Run() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	s.run()
+}
+
+// TryRun starts a run in the background unless one is already in progress.
+// It reports whether a run was started.
+func (s *Server) TryRun() bool {
+	if !s.runMu.TryLock() {
+		return false
+	}
+
+	go func() {
+		defer s.runMu.Unlock()
+		s.run()
+	}()
+	return true
+}
+
+// run runs every check and stores the results. The caller must hold runMu.
+func (s *Server) run() {
+	summary := s.runner.Run()
+	summary.Print(log.Writer())
+	if err := runner.ExportMetrics(s.cfg.Metrics); err != nil {
+		log.Printf("✗ Failed to export metrics: %v", err)
+	}
+
+	s.store(summary)
+}
+
+// store records the results of a completed run
+func (s *Server) store(summary *runner.Summary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRun = &runInfo{
+		StartedAt:  summary.StartedAt,
+		FinishedAt: summary.FinishedAt,
+		ExitCode:   summary.ExitCode(),
+		Stretched:  []report.Stretched{},
+	}
+	for _, stretched := range summary.Stretched {
+		s.lastRun.Stretched = append(s.lastRun.Stretched, report.NewStretched(stretched))
+	}
+
+	for _, result := range summary.Results {
+		dc := report.NewDCResult(result, s.cfg.NetboxURL)
+		checkedAt := summary.FinishedAt
+		s.results[key(result.DCName, result.Infra)] = dcResult{
+			DCName:    result.DCName,
+			Infra:     result.Infra,
+			CheckedAt: &checkedAt,
+			Result:    &dc,
+		}
+	}
+
+	// A failed check keeps its last result, but reports the error
+	for _, f := range summary.Failures {
+		k := key(f.Check.DCName, f.Check.Infra)
+		state := s.results[k]
+		state.DCName = f.Check.DCName
+		state.Infra = f.Check.Infra
+		state.Error = f.Err.Error()
+		s.results[k] = state
+	}
+}
+
+// handleHealthz reports that the process is alive
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports ready once the first run has completed
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	ready := s.lastRun != nil
+	s.mu.RUnlock()
+
+	if !ready {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "waiting for first run"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// handleResults returns the latest result of every check
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.snapshot(""))
+}
+
+// handleDCResults returns the latest results for a single DC
+func (s *Server) handleDCResults(w http.ResponseWriter, r *http.Request) {
+	response := s.snapshot(r.PathValue("dc"))
+	if len(response.Results) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no results for DC " + r.PathValue("dc")})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleRun triggers an ad-hoc run
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if !s.TryRun() {
+		writeJSON(w, http.StatusConflict, map[string]string{"status": "a run is already in progress"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "run started"})
+}
+
+// handleMetrics serves the metrics registry
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := metrics.Default.Write(w); err != nil {
+		log.Printf("✗ Failed to write metrics: %v", err)
+	}
+}
+
+// snapshot returns the latest results, limited to a DC if dcName is set
+func (s *Server) snapshot(dcName string) resultsResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	response := resultsResponse{
+		LastRun: s.lastRun,
+		Results: []dcResult{},
+	}
+	for _, state := range s.results {
+		if dcName == "" || state.DCName == dcName {
+			response.Results = append(response.Results, state)
+		}
+	}
+	sort.Slice(response.Results, func(i, j int) bool {
+		return key(response.Results[i].DCName, response.Results[i].Infra) < key(response.Results[j].DCName, response.Results[j].Infra)
+	})
+
+	return response
+}
+
+// key identifies a check by DC and infra
+func key(dcName, infra string) string {
+	return dcName + "/" + infra
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("✗ Failed to write response: %v", err)
+	}
+}
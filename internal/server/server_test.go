@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/models"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/runner"
+)
+
+// source serves a single matching VLAN from Netbox and VxLAN from NAM. Fetches fail while err is set
+// and wait for release while it is set.
+type source struct {
+	mu      sync.Mutex
+	err     error
+	release chan struct{}
+	started chan struct{}
+}
+
+// wait blocks a fetch until the source is released and returns the error
+func (s *source) wait() error {
+	s.mu.Lock()
+	release, started, err := s.release, s.started, s.err
+	s.started = nil
+	s.mu.Unlock()
+
+	if release != nil {
+		if started != nil {
+			close(started)
+		}
+		<-release
+	}
+	return err
+}
+
+func (s *source) FetchVLANs(siteID int) ([]models.NetboxVLAN, error) {
+	vlan := models.NetboxVLAN{ID: 1, VID: 100, Name: "app-100", CustomFields: map[string]interface{}{"infra": "infra-a"}}
+	return []models.NetboxVLAN{vlan}, s.wait()
+}
+
+func (s *source) FetchPrefixes(siteID int) ([]models.NetboxPrefix, error) {
+	return nil, s.wait()
+}
+
+func (s *source) FetchVxLANs(container string) ([]models.NAMVxLAN, error) {
+	vxlan := models.NAMVxLAN{ID: 100, Name: "app-100", Containers: []models.Container{{ID: 1, Name: container}}}
+	return []models.NAMVxLAN{vxlan}, s.wait()
+}
+
+func (s *source) FetchSubnets(container string) ([]models.NAMSubnet, error) {
+	return nil, s.wait()
+}
+
+// newTestServer creates a server checking dc1 against src and an HTTP
+// server for its API
+func newTestServer(t *testing.T, src *source) (*Server, *httptest.Server) {
+	t.Helper()
+	cfg := &config.Config{
+		Checks: []config.Check{{NetboxSiteID: 1, Infra: "infra-a", DCName: "dc1"}},
+	}
+	// A replay does not report to ESM or Slack
+	s := New(cfg, runner.New(cfg, src, src, io.Discard, runner.Options{Replay: true}))
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// do sends a request and decodes the JSON response into body
+func do(t *testing.T, method, url string, body interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestReadyz(t *testing.T) {
+	s, ts := newTestServer(t, &source{})
+
+	var status map[string]string
+	if code := do(t, http.MethodGet, ts.URL+"/readyz", &status); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz before the first run = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	s.Run()
+	if code := do(t, http.MethodGet, ts.URL+"/readyz", &status); code != http.StatusOK {
+		t.Errorf("readyz after the first run = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestDCResultsUnknownDC(t *testing.T) {
+	s, ts := newTestServer(t, &source{})
+	s.Run()
+
+	var response resultsResponse
+	if code := do(t, http.MethodGet, ts.URL+"/results/dc1", &response); code != http.StatusOK || len(response.Results) != 1 {
+		t.Errorf("results for dc1 = %d with %d results, want %d with 1", code, len(response.Results), http.StatusOK)
+	}
+
+	var body map[string]string
+	if code := do(t, http.MethodGet, ts.URL+"/results/dc9", &body); code != http.StatusNotFound {
+		t.Errorf("results for unknown DC = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestRunInProgress(t *testing.T) {
+	src := &source{release: make(chan struct{}), started: make(chan struct{})}
+	started := src.started
+	s, ts := newTestServer(t, src)
+
+	var body map[string]string
+	if code := do(t, http.MethodPost, ts.URL+"/run", &body); code != http.StatusAccepted {
+		t.Fatalf("first run = %d, want %d", code, http.StatusAccepted)
+	}
+	<-started
+
+	if code := do(t, http.MethodPost, ts.URL+"/run", &body); code != http.StatusConflict {
+		t.Errorf("run while a run is in progress = %d, want %d", code, http.StatusConflict)
+	}
+
+	// Run waits for the run in progress to finish
+	close(src.release)
+	s.Run()
+	if code := do(t, http.MethodPost, ts.URL+"/run", &body); code != http.StatusAccepted {
+		t.Errorf("run after the run finished = %d, want %d", code, http.StatusAccepted)
+	}
+	s.Run()
+}
+
+func TestFailedRunKeepsLastResult(t *testing.T) {
+	src := &source{}
+	s, ts := newTestServer(t, src)
+	s.Run()
+
+	var first resultsResponse
+	do(t, http.MethodGet, ts.URL+"/results/dc1", &first)
+	if len(first.Results) != 1 || first.Results[0].Result == nil {
+		t.Fatalf("results after a good run = %+v, want one result", first.Results)
+	}
+
+	src.mu.Lock()
+	src.err = errors.New("netbox is down")
+	src.mu.Unlock()
+	s.Run()
+
+	var second resultsResponse
+	if code := do(t, http.MethodGet, ts.URL+"/results/dc1", &second); code != http.StatusOK {
+		t.Fatalf("results after a failed run = %d, want %d", code, http.StatusOK)
+	}
+	if len(second.Results) != 1 {
+		t.Fatalf("results after a failed run = %+v, want one result", second.Results)
+	}
+	got := second.Results[0]
+	if got.Result == nil || !got.CheckedAt.Equal(*first.Results[0].CheckedAt) {
+		t.Errorf("result after a failed run = %+v, want the last good result", got)
+	}
+	if got.Error == "" {
+		t.Error("result after a failed run has no error")
+	}
+}
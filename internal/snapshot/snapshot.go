@@ -0,0 +1,125 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/client"
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+// Files describing a recorded run next to its pages
+const (
+	configFile = "config.json"
+	runFile    = "run.json"
+)
+
+// run is the content of runFile
+type run struct {
+	StartedAt time.Time `json:"started_at"`
+}
+
+// unsafeChars matches characters not allowed in a file name
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// RunDir returns the directory a run started at the given time is recorded
+// to below recordDir
+func RunDir(recordDir string, startedAt time.Time) string {
+	return filepath.Join(recordDir, startedAt.UTC().Format("20060102T150405Z"))
+}
+
+// Dir is a recorded run directory. It holds the raw response body of every
+// list page fetched from Netbox and NAM, exactly as the API returned it, so
+// a replay parses the same data as the recorded run.
+type Dir struct {
+	path string
+}
+
+// Compile time check that Dir can record and replay the API clients
+var _ client.PageStore = (*Dir)(nil)
+
+// Open returns the run directory at path. It is created when the first
+// page is saved.
+func Open(path string) *Dir {
+	return &Dir{path: path}
+}
+
+// SavePage writes the body of a page
+func (d *Dir) SavePage(name string, page int, body []byte) error {
+	path := d.pagePath(name, page)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadPage reads the body of a recorded page
+func (d *Dir) LoadPage(name string, page int) ([]byte, error) {
+	body, err := os.ReadFile(d.pagePath(name, page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return body, nil
+}
+
+// SaveRun writes the config of a run without its secrets and the time the
+// run started, so a replay uses the same checks and suppressions
+func (d *Dir) SaveRun(cfg *config.Config, startedAt time.Time) error {
+	if err := os.MkdirAll(d.path, 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(cfg.WithoutSecrets(), "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(d.path, configFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot config: %w", err)
+	}
+
+	data, err = json.MarshalIndent(run{StartedAt: startedAt}, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(d.path, runFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot run: %w", err)
+	}
+	return nil
+}
+
+// LoadRun reads the config and start time of a recorded run
+func (d *Dir) LoadRun() (*config.Config, time.Time, error) {
+	cfg, err := config.LoadConfigFile(filepath.Join(d.path, configFile))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load recorded config: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(d.path, runFile))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read snapshot run: %w", err)
+	}
+	var r run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse snapshot run: %w", err)
+	}
+	return cfg, r.StartedAt, nil
+}
+
+// pagePath returns the file holding a page. Every element of the name is
+// made safe to use as a file name.
+func (d *Dir) pagePath(name string, page int) string {
+	elems := []string{d.path}
+	for _, elem := range strings.Split(name, "/") {
+		elems = append(elems, unsafeChars.ReplaceAllString(elem, "_"))
+	}
+	elems = append(elems, fmt.Sprintf("page-%d.json", page))
+	return filepath.Join(elems...)
+}
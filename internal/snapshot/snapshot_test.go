@@ -0,0 +1,52 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NorskHelsenett/dcn-netbox-infra-check/internal/config"
+)
+
+func TestSaveAndLoadRun(t *testing.T) {
+	cfg := &config.Config{
+		NetboxURL:      "https://netbox.example",
+		NetboxAPIToken: "netbox-token",
+		SlackWebhook:   "https://hooks.slack.example/token",
+		Checks: []config.Check{
+			{NetboxSiteID: 1, Infra: "infra-a", DCName: "dc1"},
+		},
+		Suppressions: []config.Suppression{
+			{DCName: "dc1", NameRegex: "^app-", Reason: "maintenance", Expires: "2026-10-31"},
+		},
+	}
+	startedAt := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	dir := Open(filepath.Join(t.TempDir(), "run"))
+	if err := dir.SaveRun(cfg, startedAt); err != nil {
+		t.Fatalf("SaveRun: %v", err)
+	}
+	got, at, err := dir.LoadRun()
+	if err != nil {
+		t.Fatalf("LoadRun: %v", err)
+	}
+
+	if !at.Equal(startedAt) {
+		t.Errorf("start time = %v, want %v", at, startedAt)
+	}
+	if got.NetboxURL != cfg.NetboxURL || len(got.Checks) != 1 || len(got.Suppressions) != 1 {
+		t.Errorf("config = %+v, want the saved one", got)
+	}
+	if got.NetboxAPIToken != "" || got.SlackWebhook != "" {
+		t.Errorf("config has secrets: token %q, webhook %q", got.NetboxAPIToken, got.SlackWebhook)
+	}
+	if cfg.SlackWebhook == "" {
+		t.Error("SaveRun changed the config of the run")
+	}
+}
+
+func TestLoadRunMissing(t *testing.T) {
+	if _, _, err := Open(t.TempDir()).LoadRun(); err == nil {
+		t.Error("LoadRun of a directory without a recorded run succeeded")
+	}
+}